@@ -0,0 +1,102 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	k "github.com/tejabeta/kopy/internal"
+	"github.com/tejabeta/kopy/internal/options"
+	"github.com/tejabeta/kopy/pkg/bundle"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportNS     []string
+	exportNSSel  string
+	exportFile   string
+	exportFormat string
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export namespaces into a manifest bundle on disk",
+	Long: `Export namespaces into a manifest bundle on disk
+
+The resources are sanitized the same way as while copying them
+and written as a single multi-document YAML file, a per-kind
+directory tree or a tar.gz archive, along with an index file
+describing the content of the bundle.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(exportNS) == 0 && exportNSSel == "" {
+			log.Errorln(errors.New("either --ns or --ns-selector is required"))
+			os.Exit(1)
+		}
+
+		if err := validateSelectorFlags(); err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+
+		if err := validateClusterFlags("source", source); err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+
+		if err := validateConcurrencyFlags(); err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+
+		options, err := options.GetSourceOptions(sourceCluster())
+		if err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+		options.Namespaces = exportNS
+		options.NamespaceSelector = exportNSSel
+		options.IncludeKinds = includeKinds
+		options.ExcludeKinds = excludeKinds
+		options.IncludeJobs = includeJobs
+		setSelectorOptions(options)
+		options.SetConcurrency(parallel, qps, burst)
+
+		if err := k.Export(options, exportFile, exportFormat); err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringSliceVarP(&exportNS, "ns", "n", nil, "Namespaces to export, repeat the flag or separate them with commas")
+	exportCmd.Flags().StringVar(&exportNSSel, "ns-selector", "", "Label selector to pick the namespaces to export, e.g. team=payments")
+	addClusterFlags(exportCmd, "source", "s", &source)
+	exportCmd.Flags().Lookup("source-context").Usage = "Source Context name to export resources from, or " + options.InCluster + " for the cluster kopy runs in. If empty takes current context."
+	exportCmd.Flags().StringVarP(&exportFile, "file", "f", "", "File or directory to write the bundle into(required)")
+	exportCmd.Flags().StringVar(&exportFormat, "format", bundle.FormatYAML, "Bundle format, one of "+strings.Join(bundle.Formats, ", "))
+	addKindFlags(exportCmd)
+	addSelectorFlags(exportCmd)
+	addConcurrencyFlags(exportCmd)
+	exportCmd.MarkFlagRequired("file")
+}
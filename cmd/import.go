@@ -0,0 +1,71 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	k "github.com/tejabeta/kopy/internal"
+	"github.com/tejabeta/kopy/internal/options"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	importNS   []string
+	importFile string
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import a manifest bundle into a context",
+	Long: `Import a manifest bundle into a context
+
+Reads YAML or JSON manifests from a file, a directory tree
+or a tar.gz archive, such as the bundles written by export,
+and creates them in the destination context the same way
+resources are copied from a source context.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := validateCopyFlags(); err != nil {
+			log.Errorln(err)
+			os.Exit(k.ExitFailure)
+		}
+
+		options, err := options.GetDestinationOptions(destinationCluster())
+		if err != nil {
+			log.Errorln(err)
+			os.Exit(k.ExitFailure)
+		}
+		options.Namespaces = importNS
+		setCopyOptions(options)
+
+		if err := k.Import(options, importFile); err != nil {
+			log.Errorln(err)
+			os.Exit(k.ExitCode(err))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVarP(&importFile, "file", "f", "", "File, directory or tar.gz archive to read the bundle from(required)")
+	importCmd.Flags().StringSliceVarP(&importNS, "ns", "n", nil, "Namespaces of the bundle to import. If empty imports all of them.")
+	addCopyFlags(importCmd)
+	importCmd.MarkFlagRequired("file")
+}
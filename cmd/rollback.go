@@ -0,0 +1,62 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	k "github.com/tejabeta/kopy/internal"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackDryRun bool
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <run-id>",
+	Short: "Delete the objects created by a previous run",
+	Long: `Delete the objects created by a previous run
+
+Every copy or import journals the objects it creates in the
+destination under ~/.kopy/runs. The objects of the given run
+are deleted in the reverse order of their creation, from the
+destination of the run unless another one is given.`,
+	Args: cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := validateDestinationFlags(false); err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+
+		if err := k.Rollback(args[0], destinationCluster(), rollbackDryRun); err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+
+	addClusterFlags(rollbackCmd, "destination", "d", &destination)
+	rollbackCmd.Flags().Lookup("destination-context").Usage = "Destination Context name to delete the objects from. If empty takes the one of the run."
+	rollbackCmd.Flags().Lookup("destination-kubeconfig").Usage = "Path of the kubeconfig file to read the destination context from. If empty takes --kubeconfig or the one of the run."
+	rollbackCmd.Flags().BoolVar(&rollbackDryRun, "dry-run", false, "Print the objects which would be deleted without deleting them")
+}
@@ -14,8 +14,10 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	k "github.com/tejabeta/kopy/internal"
@@ -23,14 +25,43 @@ import (
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 var (
-	nameSpace     string
-	sourceContext string
-	destContext   string
+	nameSpaces    []string
+	nsSelector    string
+	kubeconfig    string
+	source        = options.NewCluster()
+	destination   = options.NewCluster()
+	targetNS      string
+	autoTargetNS  bool
+	dryRun        bool
+	mode          string
+	serverSide    bool
+	forceConflict bool
 	allResource   bool
+	includeKinds  []string
+	excludeKinds  []string
+	includeJobs   bool
+	labelSel      string
+	fieldSel      string
+	includeNames  []string
+	excludeNames  []string
+	roots         []string
+	skips         []string
+	noDefSkips    bool
+	storageClass  map[string]string
+	parallel      int
+	atomicRun     bool
+	keepNS        bool
+	continueOnErr bool
+	output        string
+	qps           float32
+	burst         int
 )
 
 var cfgFile string
@@ -43,31 +74,274 @@ var rootCmd = &cobra.Command{
 
 kopy is a kubectl plugin or a cli to copy K8s resources
 from one context to another context. Only requirement
-here is to have both the contexts inside your kubectl 
-config and appropriate accesses to the clusters`,
+here is to have both the contexts inside your kubeconfig
+files and appropriate accesses to the clusters. Inside a
+pod either side can be in-cluster, or be reached through
+the URL of its API server with a token and a CA file.`,
 
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
-		if options, err := readKoptions(); err == nil {
-			k.Kopy(options)
-		} else {
+		options, err := readKoptions()
+		if err != nil {
+			log.Errorln(err)
+			os.Exit(k.ExitFailure)
+		}
+
+		if err := k.Kopy(options); err != nil {
 			log.Errorln(err)
-			return
+			os.Exit(k.ExitCode(err))
 		}
 	},
 }
 
 func readKoptions() (*options.KopyOptions, error) {
-	options, err := options.GetKopyOptions(sourceContext, destContext)
+	if len(nameSpaces) == 0 && nsSelector == "" {
+		return nil, errors.New("either --ns or --ns-selector is required")
+	}
+
+	if err := validateCopyFlags(); err != nil {
+		return nil, err
+	}
+
+	if err := validateClusterFlags("source", source); err != nil {
+		return nil, err
+	}
+
+	if err := validateSelectorFlags(); err != nil {
+		return nil, err
+	}
+
+	options, err := options.GetKopyOptions(sourceCluster(), destinationCluster())
 	if err != nil {
 		return nil, err
 	}
-	options.Namespace = nameSpace
+	options.Namespaces = nameSpaces
+	options.NamespaceSelector = nsSelector
 	options.AllResource = allResource
+	options.IncludeKinds = includeKinds
+	options.ExcludeKinds = excludeKinds
+	options.IncludeJobs = includeJobs
+	setSelectorOptions(options)
+	setCopyOptions(options)
 	return options, nil
 }
 
+// sourceCluster tells where to load the source context from, --source-kubeconfig
+// takes precedence over --kubeconfig
+func sourceCluster() options.Cluster {
+	if *source.KubeConfig == "" {
+		*source.KubeConfig = kubeconfig
+	}
+	return source
+}
+
+// destinationCluster tells where to load the destination context from,
+// --destination-kubeconfig takes precedence over --kubeconfig
+func destinationCluster() options.Cluster {
+	if *destination.KubeConfig == "" {
+		*destination.KubeConfig = kubeconfig
+	}
+	return destination
+}
+
+// validateDestinationFlags validates the flags telling how to reach the destination,
+// which is required unless it can be taken from elsewhere
+func validateDestinationFlags(required bool) error {
+	if required && *destination.Context == "" && *destination.APIServer == "" {
+		return errors.New("either --destination-context or --destination-server is required")
+	}
+	return validateClusterFlags("destination", destination)
+}
+
+func validateClusterFlags(side string, cluster options.Cluster) error {
+	if *cluster.BearerToken != "" && cluster.TokenFile != "" {
+		return fmt.Errorf("--%v-token and --%v-token-file are mutually exclusive", side, side)
+	}
+
+	return nil
+}
+
+// validateCopyFlags validates the flags shared by the commands writing into a destination
+func validateCopyFlags() error {
+	if !isValidMode(mode) {
+		return fmt.Errorf("invalid mode %v, must be one of %v", mode, strings.Join(options.Modes, ", "))
+	}
+
+	if forceConflict && !serverSide {
+		return errors.New("--force-conflicts can only be used with --server-side")
+	}
+
+	if targetNS != "" && autoTargetNS {
+		return errors.New("--target-ns and --auto-target-ns are mutually exclusive")
+	}
+
+	if keepNS && !atomicRun {
+		return errors.New("--keep-namespace can only be used with --atomic")
+	}
+
+	if output != "" && !isValidOutput(output) {
+		return fmt.Errorf("invalid output %v, must be one of %v", output, strings.Join(options.Outputs, ", "))
+	}
+
+	if continueOnErr && atomicRun {
+		return errors.New("--continue-on-error and --atomic are mutually exclusive")
+	}
+
+	if err := validateDestinationFlags(true); err != nil {
+		return err
+	}
+
+	return validateConcurrencyFlags()
+}
+
+// validateConcurrencyFlags validates the flags shared by the commands talking to a cluster
+func validateConcurrencyFlags() error {
+	if parallel < 1 {
+		return errors.New("--parallel must be at least 1")
+	}
+
+	if qps < 0 || burst < 0 {
+		return errors.New("--qps and --burst can't be negative")
+	}
+	return nil
+}
+
+// setCopyOptions sets the options of the flags shared by the commands writing into a destination
+func setCopyOptions(kopyOptions *options.KopyOptions) {
+	kopyOptions.TargetNamespace = targetNS
+	kopyOptions.AutoTargetNS = autoTargetNS
+	kopyOptions.DryRun = dryRun
+	kopyOptions.Mode = mode
+	kopyOptions.ServerSide = serverSide
+	kopyOptions.ForceConflicts = forceConflict
+	kopyOptions.Atomic = atomicRun
+	kopyOptions.KeepNamespace = keepNS
+	kopyOptions.ContinueOnError = continueOnErr
+	kopyOptions.Output = output
+	kopyOptions.StorageClasses = storageClass
+	kopyOptions.SetConcurrency(parallel, qps, burst)
+}
+
+// addCopyFlags adds the flags shared by the commands writing into a destination
+func addCopyFlags(cmd *cobra.Command) {
+	addClusterFlags(cmd, "destination", "d", &destination)
+	cmd.Flags().Lookup("destination-context").Usage = "Destination Context name to copy resources into, or " + options.InCluster + " for the cluster kopy runs in. Required unless --destination-server is given."
+	cmd.Flags().StringVarP(&targetNS, "target-ns", "t", "", "Namespace to copy resources into. If empty takes the source namespace name.")
+	cmd.Flags().BoolVar(&autoTargetNS, "auto-target-ns", false, "Copy resources into an auto-generated namespace named <ns>-<user>-<shortid>")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the resources which would be copied without persisting them, validated by the server where possible")
+	cmd.Flags().StringVar(&mode, "mode", options.ModeCreateOnly, "How to copy into an existing destination namespace, one of "+strings.Join(options.Modes, ", "))
+	cmd.Flags().BoolVar(&serverSide, "server-side", false, "Copy resources with server-side apply using the kopy field manager")
+	cmd.Flags().BoolVar(&forceConflict, "force-conflicts", false, "Take over the fields managed by others on conflicts, only used with --server-side")
+	cmd.Flags().BoolVar(&atomicRun, "atomic", false, "Delete the objects created by the run when it fails or is interrupted")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write a report of the run to stdout, one of "+strings.Join(options.Outputs, ", ")+". Logs and tables go to stderr.")
+	cmd.Flags().StringToStringVar(&storageClass, "storage-class", nil, "Storage classes to request in the destination, as <source>=<destination> pairs. * maps any other class, e.g. standard=gp2,*=gp2")
+	cmd.Flags().BoolVar(&continueOnErr, "continue-on-error", false, "Keep copying the other objects of a namespace when one of them fails")
+	cmd.Flags().BoolVar(&keepNS, "keep-namespace", false, "Keep the namespaces created by the run while rolling it back, only used with --atomic")
+	addConcurrencyFlags(cmd)
+}
+
+// addClusterFlags adds the flags of kubectl telling how to reach a cluster,
+// prefixed with its side e.g. --source-as, along with the file holding its token
+func addClusterFlags(cmd *cobra.Command, side string, shorthand string, cluster *options.Cluster) {
+	flags := pflag.NewFlagSet(side, pflag.ContinueOnError)
+	cluster.AddFlags(flags)
+	flags.VisitAll(func(flag *pflag.Flag) {
+		flag.Shorthand = ""
+		if flag.Name == "context" {
+			flag.Shorthand = shorthand
+		}
+		flag.Name = side + "-" + flag.Name
+		flag.Usage = strings.Title(side) + " cluster: " + flag.Usage
+		cmd.Flags().AddFlag(flag)
+	})
+
+	cmd.Flags().StringVar(&cluster.TokenFile, side+"-token-file", "", strings.Title(side)+" cluster: Path of the file holding the bearer token for authentication to the API server, read again when it's rotated")
+	cmd.Flags().Lookup(side + "-kubeconfig").Usage = "Path of the kubeconfig file to read the " + side + " context from. If empty takes --kubeconfig."
+}
+
+// normalizeClusterFlags accepts the dest- prefix of the destination flags and
+// the kubectl namespace flags, e.g. --dest-as or --source-namespace
+func normalizeClusterFlags(f *pflag.FlagSet, name string) pflag.NormalizedName {
+	if strings.HasPrefix(name, "dest-") {
+		name = "destination-" + strings.TrimPrefix(name, "dest-")
+	}
+
+	switch name {
+	case "source-namespace":
+		name = "ns"
+	case "destination-namespace":
+		name = "target-ns"
+	}
+	return pflag.NormalizedName(name)
+}
+
+// addKindFlags adds the flags filtering the kinds of resources read from the source
+func addKindFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&includeKinds, "include-kinds", nil, "Only copy these resource types, by name, kind or short name optionally qualified by group, e.g. deploy,cm,ingresses.networking.k8s.io")
+	cmd.Flags().BoolVar(&includeJobs, "include-jobs", false, "Copy the Jobs which are not created by a CronJob, they run again in the destination")
+	cmd.Flags().StringSliceVar(&excludeKinds, "exclude-kinds", nil, "Don't copy these resource types, by name, kind or short name optionally qualified by group, e.g. secrets")
+}
+
+// addSelectorFlags adds the flags filtering the objects read from the source
+func addSelectorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&labelSel, "selector", "l", "", "Label selector to filter the objects to copy, e.g. app=checkout")
+	cmd.Flags().StringVar(&fieldSel, "field-selector", "", "Field selector to filter the objects to copy, e.g. metadata.name=checkout")
+	cmd.Flags().StringSliceVar(&includeNames, "include-names", nil, "Only copy the objects with a name matching one of these glob patterns, e.g. checkout-*")
+	cmd.Flags().StringSliceVar(&excludeNames, "exclude-names", nil, "Don't copy the objects with a name matching one of these glob patterns")
+	cmd.Flags().StringSliceVar(&roots, "root", nil, "Only copy this object and the objects it depends on, as <type>/<name> e.g. deploy/checkout")
+	cmd.Flags().StringSliceVar(&skips, "skip", nil, "Don't copy the objects of a type with a name matching a glob pattern, as <type>/<pattern> e.g. secret/sh.helm.release.*")
+	cmd.Flags().BoolVar(&noDefSkips, "no-default-skips", false, "Also copy the objects generated by the source cluster and its controllers, such as service account tokens and objects owned by a controller")
+}
+
+// validateSelectorFlags validates the flags filtering the objects read from the source
+func validateSelectorFlags() error {
+	if _, err := labels.Parse(labelSel); err != nil {
+		return fmt.Errorf("invalid --selector: %v", err)
+	}
+
+	if _, err := fields.ParseSelector(fieldSel); err != nil {
+		return fmt.Errorf("invalid --field-selector: %v", err)
+	}
+	return nil
+}
+
+// setSelectorOptions sets the options of the flags filtering the objects read from the source
+func setSelectorOptions(kopyOptions *options.KopyOptions) {
+	kopyOptions.LabelSelector = labelSel
+	kopyOptions.FieldSelector = fieldSel
+	kopyOptions.IncludeNames = includeNames
+	kopyOptions.ExcludeNames = excludeNames
+	kopyOptions.Roots = roots
+	kopyOptions.Skips = skips
+	kopyOptions.NoDefaultSkips = noDefSkips
+}
+
+// addConcurrencyFlags adds the flags shared by the commands talking to a cluster
+func addConcurrencyFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&parallel, "parallel", 1, "Number of resources to read or copy concurrently")
+	cmd.Flags().Float32Var(&qps, "qps", 0, "Maximum queries per second to each cluster. If zero scales the client-go default with --parallel.")
+	cmd.Flags().IntVar(&burst, "burst", 0, "Maximum burst of queries to each cluster. If zero scales the client-go default with --parallel.")
+}
+
+func isValidOutput(output string) bool {
+	for _, o := range options.Outputs {
+		if o == output {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidMode(mode string) bool {
+	for _, m := range options.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -80,11 +354,16 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.Flags().StringVarP(&nameSpace, "ns", "n", "", "Namespace to copy resources from(required)")
-	rootCmd.Flags().StringVarP(&sourceContext, "source-context", "s", "", "Source Context name to copy resources from. If empty takes current context.")
-	rootCmd.Flags().StringVarP(&destContext, "destination-context", "d", "", "Destination Context name to copy resources into(required)")
-	rootCmd.MarkFlagRequired("ns")
-	rootCmd.MarkFlagRequired("destination-context")
+	rootCmd.SetGlobalNormalizationFunc(normalizeClusterFlags)
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "Path of the kubeconfig file. If empty reads the files listed by the KUBECONFIG env variable merged together, or ~/.kube/config")
+
+	rootCmd.Flags().StringSliceVarP(&nameSpaces, "ns", "n", nil, "Namespaces to copy resources from, repeat the flag or separate them with commas")
+	rootCmd.Flags().StringVar(&nsSelector, "ns-selector", "", "Label selector to pick the namespaces to copy resources from, e.g. team=payments")
+	addClusterFlags(rootCmd, "source", "s", &source)
+	rootCmd.Flags().Lookup("source-context").Usage = "Source Context name to copy resources from, or " + options.InCluster + " for the cluster kopy runs in. If empty takes current context."
+	addKindFlags(rootCmd)
+	addSelectorFlags(rootCmd)
+	addCopyFlags(rootCmd)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -109,6 +388,6 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
@@ -0,0 +1,84 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tejabeta/kopy/internal/options"
+	"github.com/tejabeta/kopy/pkg/bundle"
+	"github.com/tejabeta/kopy/pkg/koperator"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Export writes the resources of the namespaces into a bundle on disk
+func Export(kopyOptions *options.KopyOptions, path string, format string) error {
+	namespaces, err := getNamespaces(kopyOptions)
+	if err != nil {
+		return err
+	}
+
+	if len(namespaces) == 0 {
+		return fmt.Errorf("no namespaces found to export")
+	}
+
+	b := bundle.Bundle{Source: kopyOptions.SourceContext.Host, Namespaces: namespaces}
+	for _, ns := range namespaces {
+		objects, err := exportNamespace(kopyOptions, ns)
+		if err != nil {
+			return fmt.Errorf("exporting namespace %v failed: %v", ns, err)
+		}
+		b.Objects = append(b.Objects, objects...)
+	}
+
+	if err := b.Write(path, format); err != nil {
+		return err
+	}
+
+	log.Infof("Exported %v objects of %v namespaces into %v", len(b.Objects), len(namespaces), path)
+	return nil
+}
+
+// exportNamespace returns the sanitized namespace and its resources
+func exportNamespace(kopyOptions *options.KopyOptions, namespace string) ([]*unstructured.Unstructured, error) {
+	sourceKOpts, err := koperator.GetOpts(kopyOptions.SourceContext, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := sourceKOpts.GetNS()
+	if err != nil {
+		return nil, err
+	}
+
+	sResources, err := getResources(sourceKOpts, kopyOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*unstructured.Unstructured
+	for _, v := range append([]interface{}{ns}, sResources.ordered(namespace)...) {
+		koperator.ManipulateResource(v)
+		obj, err := koperator.ToUnstructured(v)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, obj)
+		log.Infof("Exported resource %v of type %v", obj.GetName(), obj.GetKind())
+	}
+
+	return result, nil
+}
@@ -0,0 +1,140 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tejabeta/kopy/internal/options"
+	"github.com/tejabeta/kopy/pkg/bundle"
+	"github.com/tejabeta/kopy/pkg/koperator"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Import creates the resources of a bundle on disk in the destination, the same
+// way as they are copied from a source context
+func Import(kopyOptions *options.KopyOptions, path string) error {
+	start := time.Now()
+	results, tx, err := importBundle(kopyOptions, path)
+	return writeReport(kopyOptions, start, tx, results, err)
+}
+
+// importBundle imports the namespaces of the bundle one after the other and returns their outcome
+func importBundle(kopyOptions *options.KopyOptions, path string) (results []nsResult, tx *transaction, err error) {
+	b, err := bundle.Read(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	namespaces := b.Namespaces
+	if len(kopyOptions.Namespaces) > 0 {
+		namespaces, err = pickNamespaces(b.Namespaces, kopyOptions.Namespaces)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(namespaces) == 0 {
+		return nil, nil, errors.New("no namespaces found to import")
+	}
+
+	if kopyOptions.TargetNamespace != "" && len(namespaces) > 1 {
+		return nil, nil, errors.New("target namespace can only be used while importing a single namespace")
+	}
+
+	for _, obj := range b.Objects {
+		if obj.GetNamespace() == "" && obj.GetKind() != "Namespace" {
+			log.Warn("Resource ", obj.GetName(), " of type ", obj.GetKind(), " has no namespace and is not imported.")
+		}
+	}
+
+	converter, err := newConverter(kopyOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err = newTransaction(kopyOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.close()
+
+	for _, ns := range namespaces {
+		result := importNamespace(kopyOptions, b, ns, converter, tx)
+		if result.Err != nil {
+			log.Error("Importing namespace ", ns, " failed: ", result.Err)
+		}
+		results = append(results, result)
+		if result.Err != nil && kopyOptions.Atomic {
+			break
+		}
+	}
+
+	err = finish(kopyOptions, results, tx)
+	return
+}
+
+// importNamespace creates a single namespace of the bundle and its resources in the destination
+func importNamespace(kopyOptions *options.KopyOptions, b *bundle.Bundle, namespace string, converter *koperator.Converter, tx *transaction) (result nsResult) {
+	result = nsResult{Namespace: namespace, TargetNS: getTargetNS(kopyOptions, namespace)}
+	defer func(start time.Time) { result.Duration = time.Since(start) }(time.Now())
+
+	if result.Err = tx.checkInterrupted(); result.Err != nil {
+		return
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	sResources := newKopyResources()
+	for _, obj := range b.Objects {
+		switch {
+		case obj.GroupVersionKind() == corev1.SchemeGroupVersion.WithKind("Namespace"):
+			if obj.GetName() != namespace {
+				continue
+			}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ns); err != nil {
+				result.Err = err
+				return
+			}
+		case obj.GetNamespace() == namespace:
+			if err := sResources.add(obj); err != nil {
+				result.Err = fmt.Errorf("decoding %v %v failed: %v", obj.GetKind(), obj.GetName(), err)
+				return
+			}
+		}
+	}
+
+	result.Objects, result.Err = writeNamespace(kopyOptions, ns, sResources, result.TargetNS, converter, tx)
+	return
+}
+
+// pickNamespaces returns the requested namespaces, all of them must be in the bundle
+func pickNamespaces(available []string, requested []string) ([]string, error) {
+	found := map[string]bool{}
+	for _, ns := range available {
+		found[ns] = true
+	}
+
+	for _, ns := range requested {
+		if !found[ns] {
+			return nil, fmt.Errorf("no namespace %v found in bundle", ns)
+		}
+	}
+	return requested, nil
+}
@@ -0,0 +1,110 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/tejabeta/kopy/internal/options"
+)
+
+// newAPIServer serves the discovery of the core group and forbids everything else
+func newAPIServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+		case "/apis":
+			w.Write([]byte(`{"kind":"APIGroupList","groups":[]}`))
+		case "/api/v1":
+			w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+				`{"name":"namespaces","kind":"Namespace","namespaced":false,"verbs":["create","get"]},` +
+				`{"name":"configmaps","kind":"ConfigMap","namespaced":true,"verbs":["create","get"]}]}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","code":403}`))
+		}
+	}))
+}
+
+func TestImportDestinationOnly(t *testing.T) {
+
+	dir := t.TempDir()
+	homedir.DisableCache = true
+	defer func(home string) { os.Setenv("HOME", home) }(os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+
+	server := newAPIServer()
+	defer server.Close()
+
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	bundlePath := filepath.Join(dir, "bundle.yaml")
+	if err := ioutil.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	manifests := "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: unit-test-ns\n---\n" +
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: unit-test-cm\n  namespace: unit-test-ns\n"
+	if err := ioutil.WriteFile(bundlePath, []byte(manifests), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := options.NewCluster()
+	*destination.KubeConfig = kubeconfig
+	*destination.APIServer = server.URL
+	kopyOptions, err := options.GetDestinationOptions(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kopyOptions.Mode = options.ModeCreateOnly
+	kopyOptions.Output = options.OutputJSON
+
+	stdout := os.Stdout
+	out, err := ioutil.TempFile(dir, "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = out
+	err = Import(kopyOptions, bundlePath)
+	os.Stdout = stdout
+	out.Close()
+
+	if err == nil {
+		t.Errorf("Error while importing into a forbidden destination, expected an error")
+	}
+
+	content, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report runReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Error while reading the report %s: %v", content, err)
+	}
+	if report.SourceContext != "" || report.DestinationContext != server.URL {
+		t.Errorf("Error while reporting the clusters, got source %q and destination %q", report.SourceContext, report.DestinationContext)
+	}
+	if report.RunID == "" {
+		t.Errorf("Error while journaling the import, the report has no run id")
+	}
+
+}
@@ -14,209 +14,976 @@ limitations under the License.
 package internal
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/tejabeta/kopy/internal/options"
 	"github.com/tejabeta/kopy/pkg/koperator"
 	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	v1beta1 "k8s.io/api/extensions/v1beta1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 )
 
 // KopyResources as name suggests a struct type to hold all the resources
 type kopyResources struct {
 	Deployments  *[]appv1.Deployment
+	StatefulSets *[]appv1.StatefulSet
 	ConfigMaps   *[]corev1.ConfigMap
 	Roles        *[]rbacv1.Role
 	RoleBindings *[]rbacv1.RoleBinding
 	Secrets      *[]corev1.Secret
 	Services     *[]corev1.Service
-	Ingresses    *[]v1beta1.Ingress
+	Ingresses    *[]networkingv1.Ingress
+	PVCs         *[]corev1.PersistentVolumeClaim
+	Jobs         *[]batchv1.Job
+	Unstructured *[]unstructured.Unstructured
+	Skipped      []objectResult
+	// Names filters the objects by name, all of them are kept when nil
+	Names *koperator.NameFilter
+	// Only keeps the given objects when not nil
+	Only map[koperator.Reference]bool
+	// Excluded leaves out the given objects, they are reported as skipped
+	Excluded map[koperator.Reference]bool
+}
+
+// nsDeletionTimeout is how long kopy waits for a replaced namespace to be deleted
+const nsDeletionTimeout = 5 * time.Minute
+
+// typedResources are copied through the typed clients, the generic engine leaves them out
+var typedResources = map[schema.GroupResource]bool{
+	{Group: "apps", Resource: "deployments"}:                       true,
+	{Group: "apps", Resource: "statefulsets"}:                      true,
+	{Group: "", Resource: "configmaps"}:                            true,
+	{Group: "rbac.authorization.k8s.io", Resource: "roles"}:        true,
+	{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"}: true,
+	{Group: "", Resource: "secrets"}:                               true,
+	{Group: "", Resource: "services"}:                              true,
+	{Group: "", Resource: "persistentvolumeclaims"}:                true,
+	{Group: "batch", Resource: "jobs"}:                             true,
+	{Group: "extensions", Resource: "ingresses"}:                   true,
+	{Group: "networking.k8s.io", Resource: "ingresses"}:            true,
+}
+
+// ignoredResources are never copied as they only make sense in the cluster they were recorded in
+var ignoredResources = map[schema.GroupResource]bool{
+	{Group: "", Resource: "events"}:              true,
+	{Group: "events.k8s.io", Resource: "events"}: true,
+}
+
+// nsResult holds the outcome of copying a single namespace
+type nsResult struct {
+	Namespace string
+	TargetNS  string
+	Objects   []objectResult
+	Err       error
+	Duration  time.Duration
+}
+
+// Statuses of a namespace at the end of a run
+const (
+	statusCopied        = "copied"
+	statusWouldBeCopied = "would be copied"
+	statusPartial       = "partial"
+	statusFailed        = "failed"
+)
+
+// status tells if the namespace got copied, partially copied or not at all
+func (r nsResult) status(dryRun bool) string {
+	switch {
+	case r.Err == nil && dryRun:
+		return statusWouldBeCopied
+	case r.Err == nil:
+		return statusCopied
+	}
+
+	for _, o := range r.Objects {
+		if o.copied() {
+			return statusPartial
+		}
+	}
+	return statusFailed
 }
 
 // Kopy functionality goes here
-func Kopy(kopyOptions *options.KopyOptions) {
-	sourceKOpts, err := koperator.GetOpts(kopyOptions.SourceContext, kopyOptions.Namespace)
+func Kopy(kopyOptions *options.KopyOptions) error {
+	start := time.Now()
+	results, tx, err := kopy(kopyOptions)
+	return writeReport(kopyOptions, start, tx, results, err)
+}
+
+// kopy copies the namespaces one after the other and returns their outcome
+func kopy(kopyOptions *options.KopyOptions) (results []nsResult, tx *transaction, err error) {
+	namespaces, err := getNamespaces(kopyOptions)
 	if err != nil {
-		log.Errorln(err)
-		return
+		return nil, nil, err
+	}
+
+	if len(namespaces) == 0 {
+		return nil, nil, errors.New("no namespaces found to copy")
+	}
+
+	if kopyOptions.TargetNamespace != "" && len(namespaces) > 1 {
+		return nil, nil, errors.New("target namespace can only be used while copying a single namespace")
 	}
 
-	destKOpts, err := koperator.GetOpts(kopyOptions.DestinationContext, kopyOptions.Namespace)
+	converter, err := newConverter(kopyOptions)
 	if err != nil {
-		log.Errorln(err)
+		return nil, nil, err
 	}
 
-	if isValidNS(sourceKOpts) {
-		sResources, err := getResources(sourceKOpts)
-		if err != nil {
-			log.Fatalln(err)
-			return
+	tx, err = newTransaction(kopyOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.close()
+
+	for _, ns := range namespaces {
+		result := kopyNamespace(kopyOptions, ns, converter, tx)
+		if result.Err != nil {
+			log.Error("Copying namespace ", ns, " failed: ", result.Err)
+		}
+		results = append(results, result)
+		if result.Err != nil && kopyOptions.Atomic {
+			break
+		}
+	}
+
+	err = finish(kopyOptions, results, tx)
+	return
+}
+
+// finish prints the plan in dry-run and summarizes the outcome of all the namespaces
+func finish(kopyOptions *options.KopyOptions, results []nsResult, tx *transaction) error {
+	w := humanOutput(kopyOptions)
+	if kopyOptions.DryRun {
+		for _, r := range results {
+			fmt.Fprintf(w, "\nNamespace %v -> %v (dry-run)\n", r.Namespace, r.TargetNS)
+			printPlan(w, r.Objects)
 		}
+	}
+
+	err := summarize(w, results, kopyOptions.DryRun)
+	if err == nil || !kopyOptions.Atomic || tx == nil {
+		return err
+	}
+
+	if rbErr := tx.rollback(kopyOptions.DestinationContext); rbErr != nil {
+		return fmt.Errorf("%v, rolling back run %v failed: %v", err, tx.journal.RunID, rbErr)
+	}
+	return fmt.Errorf("%v, run %v is rolled back", err, tx.journal.RunID)
+}
+
+// newConverter discovers the versions served by the destination, warning about the
+// kinds of the source which have no equivalent in it
+func newConverter(kopyOptions *options.KopyOptions) (*koperator.Converter, error) {
+	converter, err := koperator.NewConverter(kopyOptions.DestinationContext)
+	if err != nil || kopyOptions.SourceContext == nil {
+		return converter, err
+	}
+
+	sourceKOpts, err := koperator.GetOpts(kopyOptions.SourceContext, "")
+	if err != nil {
+		return nil, err
+	}
+
+	apiResources, err := sourceKOpts.GetAPIResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+
+	filter := koperator.NewKindFilter(kopyOptions.IncludeKinds, kopyOptions.ExcludeKinds)
+	for _, r := range converter.Unconvertible(apiResources) {
+		if typedResources[r.GroupResource()] || ignoredResources[r.GroupResource()] || !filter.Allows(r) {
+			continue
+		}
+		log.Warnf("%v %v of the source has no equivalent in the destination, its objects are not copied", r.Kind, r.GroupVersion())
+	}
+	return converter, nil
+}
+
+// kopyNamespace copies a single namespace and its resources into the destination
+func kopyNamespace(kopyOptions *options.KopyOptions, namespace string, converter *koperator.Converter, tx *transaction) (result nsResult) {
+	result = nsResult{Namespace: namespace, TargetNS: getTargetNS(kopyOptions, namespace)}
+	defer func(start time.Time) { result.Duration = time.Since(start) }(time.Now())
 
-		if isValidNS(destKOpts) {
-			log.Error("Namespace ", kopyOptions.Namespace, " exists in destination.")
-		} else {
-			log.Info("No namespace ", kopyOptions.Namespace, " found in destination.")
-			log.Info("Namespace and resources will be created in the destination.")
+	if result.Err = tx.checkInterrupted(); result.Err != nil {
+		return
+	}
+
+	sourceKOpts, err := koperator.GetOpts(kopyOptions.SourceContext, namespace)
+	if err != nil {
+		result.Err = err
+		return
+	}
 
-			ns, err := sourceKOpts.GetNS()
+	if !isValidNS(sourceKOpts) {
+		result.Err = fmt.Errorf("no namespace %v found in source context", namespace)
+		return
+	}
+
+	sResources, err := getResources(sourceKOpts, kopyOptions)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	ns, err := sourceKOpts.GetNS()
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	result.Objects, result.Err = writeNamespace(kopyOptions, ns, sResources, result.TargetNS, converter, tx)
+	return
+}
+
+// writeNamespace creates the namespace and its resources in the destination as
+// per the mode, renaming it to the target namespace. Objects are converted to the
+// versions served by the destination
+func writeNamespace(kopyOptions *options.KopyOptions, ns *corev1.Namespace, sResources *kopyResources, targetNS string, converter *koperator.Converter, tx *transaction) (results []objectResult, err error) {
+	namespace := ns.Name
+	if targetNS != namespace {
+		log.Info("Namespace ", namespace, " will be copied as ", targetNS, " in destination.")
+	}
+
+	destKOpts, err := koperator.GetOpts(kopyOptions.DestinationContext, targetNS)
+	if err != nil {
+		return
+	}
+
+	destKOpts.SetDryRun(kopyOptions.DryRun)
+	destKOpts.SetForceConflicts(kopyOptions.ForceConflicts)
+	destKOpts.SetConverter(converter)
+
+	nsExists, replaced := isValidNS(destKOpts), false
+	if nsExists {
+		switch kopyOptions.Mode {
+		case options.ModeMerge, options.ModeOverwrite:
+			log.Info("Namespace ", targetNS, " exists in destination, resources will be copied in ", kopyOptions.Mode, " mode.")
+		case options.ModeReplace:
+			log.Info("Namespace ", targetNS, " exists in destination and will be replaced.")
+			objects, err := replaceNS(destKOpts, kopyOptions.DryRun)
+			results = append(results, objects...)
 			if err != nil {
-				log.Fatalln(err)
-				return
+				return results, err
 			}
+			nsExists, replaced = false, true
+		default:
+			return results, fmt.Errorf("namespace %v exists in destination", targetNS)
+		}
+	}
+
+	if !nsExists {
+		if !replaced {
+			log.Info("No namespace ", targetNS, " found in destination.")
+		}
+		log.Info("Namespace and resources will be created in the destination.")
 
-			koperator.ManipulateResource(ns)
+		koperator.ManipulateResource(ns)
+		koperator.SwitchNamespace(ns, namespace, targetNS)
+		if !(kopyOptions.DryRun && replaced) {
+			// The namespace still exists while replacing it in a dry-run
 			_, err = destKOpts.CreateNS(ns)
-			if err != nil {
-				log.Fatalln(err)
-				return
-			}
+		}
+		results = append(results, newObjectResult(ns, actionCreate, err))
+		if err != nil {
+			return
+		}
+		if err = tx.record(ns); err != nil {
+			return
+		}
+	}
 
-			err = createResources(destKOpts, sResources)
-			if err != nil {
-				log.Fatalln(err)
-				return
-			}
+	if kopyOptions.DryRun && !nsExists {
+		// A dry-run doesn't persist the namespace, objects in it can only be checked client-side
+		log.Warn("Namespace ", targetNS, " doesn't exist in destination, resources are not validated by the server.")
+		results = append(results, planResources(destKOpts, sResources, namespace, kopyOptions)...)
+		return
+	}
 
-			log.Info("All the resources are created in the destination.")
+	// Ingresses are converted to the version served by the destination
+	if len(*sResources.Ingresses) > 0 {
+		if err = destKOpts.ResolveIngressVersion(); err != nil {
+			return
 		}
-	} else {
-		log.Error("No namespace ", kopyOptions.Namespace, " found in source context.")
 	}
 
+	objects, err := createResources(destKOpts, sResources, namespace, kopyOptions, tx)
+	results = append(results, objects...)
+	if err != nil {
+		return
+	}
+
+	log.Info("All the resources of namespace ", namespace, " are copied into the destination.")
 	return
 }
 
-func getResources(kOpts *koperator.Options) (*kopyResources, error) {
-	deployments, err := kOpts.GetDeployments()
+// replaceNS deletes the destination namespace and waits until it is gone
+func replaceNS(kOpts *koperator.Options, dryRun bool) ([]objectResult, error) {
+	ns, err := kOpts.GetNS()
 	if err != nil {
 		return nil, err
 	}
 
-	configMaps, err := kOpts.GetConfigMaps()
-	if err != nil {
-		return nil, err
+	err = kOpts.DeleteNS(ns.Name)
+	results := []objectResult{newObjectResult(ns, actionDelete, err)}
+	if err != nil || dryRun {
+		return results, err
 	}
 
-	roles, err := kOpts.GetRoles()
-	if err != nil {
-		return nil, err
+	log.Info("Waiting for namespace ", ns.Name, " to be deleted.")
+	return results, kOpts.WaitForNSDeletion(nsDeletionTimeout)
+}
+
+// getNamespaces returns the namespaces requested by name and by label selector
+func getNamespaces(kopyOptions *options.KopyOptions) ([]string, error) {
+	var namespaces []string
+	seen := map[string]bool{}
+
+	add := func(ns string) {
+		if ns != "" && !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
 	}
 
-	roleBindings, err := kOpts.GetRoleBindings()
+	for _, ns := range kopyOptions.Namespaces {
+		add(ns)
+	}
+
+	if kopyOptions.NamespaceSelector != "" {
+		kOpts, err := koperator.GetOpts(kopyOptions.SourceContext, "")
+		if err != nil {
+			return nil, err
+		}
+
+		nsList, err := kOpts.GetNamespaces(kopyOptions.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ns := range nsList.Items {
+			add(ns.Name)
+		}
+	}
+
+	return namespaces, nil
+}
+
+// summarize prints the outcome of every namespace and returns an error if any of them failed
+func summarize(w io.Writer, results []nsResult, dryRun bool) error {
+	notCopied := "could not be copied"
+	if dryRun {
+		notCopied = "would not be copied"
+	}
+
+	var failures []objectResult
+	for i, r := range results {
+		var failed []objectResult
+		for _, o := range r.Objects {
+			if o.failed() {
+				failed = append(failed, o)
+			}
+		}
+		failures = append(failures, failed...)
+
+		switch {
+		case r.Err != nil:
+		case len(failed) == 1:
+			results[i].Err = fmt.Errorf("%v %v %v, %v", failed[0].Kind, failed[0].Name, notCopied, failed[0].Message)
+		case len(failed) > 1:
+			results[i].Err = fmt.Errorf("%v objects %v", len(failed), notCopied)
+		}
+	}
+
+	fmt.Fprintln(w, "\nSummary:")
+	printSummary(w, results, dryRun)
+	if len(failures) > 0 && !dryRun {
+		fmt.Fprintln(w, "\nFailed objects:")
+		printPlan(w, failures)
+	}
+
+	runErr := &RunError{Total: len(results)}
+	for _, r := range results {
+		if r.Err != nil {
+			runErr.Failed++
+			log.Errorf("%v -> %v: %v", r.Namespace, r.TargetNS, r.Err)
+		}
+		if r.status(dryRun) != statusFailed {
+			runErr.Partial = true
+		}
+	}
+
+	if runErr.Failed > 0 {
+		return runErr
+	}
+	return nil
+}
+
+func getResources(kOpts *koperator.Options, kopyOptions *options.KopyOptions) (*kopyResources, error) {
+	apiResources, err := kOpts.GetAPIResources()
 	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, err
+		}
+		log.Warn("Resources of some API groups can't be copied: ", err)
+	}
+
+	filter := koperator.NewKindFilter(kopyOptions.IncludeKinds, kopyOptions.ExcludeKinds)
+	if err := filter.Validate(apiResources); err != nil {
 		return nil, err
 	}
 
-	secrets, err := kOpts.GetSecrets()
+	names, err := koperator.NewNameFilter(kopyOptions.IncludeNames, kopyOptions.ExcludeNames)
 	if err != nil {
 		return nil, err
 	}
 
-	services, err := kOpts.GetSVC()
+	skipper, err := newSkipper(kopyOptions, apiResources)
 	if err != nil {
 		return nil, err
 	}
+	kOpts.SetSelectors(kopyOptions.LabelSelector, kopyOptions.FieldSelector)
+
+	var (
+		deployments  = &appv1.DeploymentList{}
+		statefulSets = &appv1.StatefulSetList{}
+		configMaps   = &corev1.ConfigMapList{}
+		roles        = &rbacv1.RoleList{}
+		roleBindings = &rbacv1.RoleBindingList{}
+		secrets      = &corev1.SecretList{}
+		services     = &corev1.ServiceList{}
+		ingresses    = &networkingv1.IngressList{}
+		pvcs         = &corev1.PersistentVolumeClaimList{}
+		jobs         = &batchv1.JobList{}
+	)
+
+	lists := []struct {
+		resource schema.GroupResource
+		kind     string
+		get      func() error
+	}{
+		{appv1.Resource("deployments"), "Deployment", func() (err error) { deployments, err = kOpts.GetDeployments(); return }},
+		{appv1.Resource("statefulsets"), "StatefulSet", func() (err error) { statefulSets, err = kOpts.GetStatefulSets(); return }},
+		{corev1.Resource("configmaps"), "ConfigMap", func() (err error) { configMaps, err = kOpts.GetConfigMaps(); return }},
+		{rbacv1.Resource("roles"), "Role", func() (err error) { roles, err = kOpts.GetRoles(); return }},
+		{rbacv1.Resource("rolebindings"), "RoleBinding", func() (err error) { roleBindings, err = kOpts.GetRoleBindings(); return }},
+		{corev1.Resource("secrets"), "Secret", func() (err error) { secrets, err = kOpts.GetSecrets(); return }},
+		{corev1.Resource("services"), "Service", func() (err error) { services, err = kOpts.GetSVC(); return }},
+		{networkingv1.Resource("ingresses"), "Ingress", func() (err error) { ingresses, err = kOpts.GetIngress(); return }},
+		{corev1.Resource("persistentvolumeclaims"), "PersistentVolumeClaim", func() (err error) { pvcs, err = kOpts.GetPVC(); return }},
+		{batchv1.Resource("jobs"), "Job", func() (err error) { jobs, err = kOpts.GetJob(); return }},
+	}
+	// Re-running jobs has side effects, they are only copied on request or when
+	// included explicitly
+	jobResource := koperator.APIResource{GroupVersionResource: batchv1.SchemeGroupVersion.WithResource("jobs"), Kind: "Job", SingularName: "job"}
+	includeJobs := kopyOptions.IncludeJobs || filter.Includes(jobResource)
+
+	errs := make([]error, len(lists))
+	forEach(kopyOptions.Parallel, len(lists), func(i int) {
+		if lists[i].resource == batchv1.Resource("jobs") && !includeJobs {
+			return
+		}
+		if allowsTyped(filter, apiResources, lists[i].resource) {
+			errs[i] = lists[i].get()
+		}
+	})
+	var unlisted []objectResult
+	for i, err := range errs {
+		switch {
+		case err == nil:
+		case unlistable(err):
+			unlisted = append(unlisted, newUnlistedResult(lists[i].kind, kOpts.Namespace(), err))
+		default:
+			return nil, err
+		}
+	}
 
-	ingresses, err := kOpts.GetIngress()
+	others, skipped, err := getUnstructuredResources(kOpts, apiResources, filter, names, kopyOptions.Parallel)
 	if err != nil {
 		return nil, err
 	}
+	unlisted = append(unlisted, skipped...)
 
 	kopyResources := kopyResources{
 		Deployments:  &deployments.Items,
+		StatefulSets: &statefulSets.Items,
 		ConfigMaps:   &configMaps.Items,
 		Roles:        &roles.Items,
 		RoleBindings: &roleBindings.Items,
 		Secrets:      &secrets.Items,
 		Services:     &services.Items,
 		Ingresses:    &ingresses.Items,
+		PVCs:         &pvcs.Items,
+		Jobs:         &jobs.Items,
+		Unstructured: &others,
+		Skipped:      unlisted,
+		Names:        names,
+	}
+	kopyResources.skip(skipper)
+
+	if len(kopyOptions.Roots) > 0 {
+		if err := kopyResources.keepClosure(kopyOptions.Roots, apiResources); err != nil {
+			return nil, fmt.Errorf("%v in namespace %v", err, kOpts.Namespace())
+		}
 	}
 
 	return &kopyResources, nil
 }
 
-func createResources(kOpts *koperator.Options, kResource *kopyResources) error {
+// keepClosure only keeps the given objects and the ones they depend on
+func (kResource *kopyResources) keepClosure(roots []string, apiResources []koperator.APIResource) error {
+	var refs []koperator.Reference
+	for _, root := range roots {
+		ref, err := koperator.ParseReference(root, apiResources)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, ref)
+	}
 
-	if len(*kResource.Deployments) > 0 {
-		for _, v := range *kResource.Deployments {
-			koperator.ManipulateResource(&v)
-			_, err := kOpts.CreateDeployment(&v)
-			if err != nil {
-				return err
-			}
-			log.Infof("Copied resource %v of type Deployment", v.GetName())
+	// Skipped objects are part of the closure, so they are reported and pull in
+	// the objects depending on them
+	closure, err := koperator.Closure(kResource.named(), refs)
+	if err != nil {
+		return err
+	}
+
+	// Objects left out of the closure are not part of the copy at all, the kinds
+	// which can't be listed are reported all the same
+	var skipped []objectResult
+	for _, r := range kResource.Skipped {
+		if r.Name == "" || closure[r.ref] {
+			skipped = append(skipped, r)
 		}
 	}
+	kResource.Only, kResource.Skipped = closure, skipped
+	return nil
+}
 
-	if len(*kResource.ConfigMaps) > 0 {
-		for _, v := range *kResource.ConfigMaps {
-			koperator.ManipulateResource(&v)
-			_, err := kOpts.CreateConfigMap(&v)
-			if err != nil {
-				return err
-			}
-			log.Infof("Copied resource %v of type Configmap", v.GetName())
+// newSkipper returns the skipper of the built-in rules, unless disabled, and of the
+// <type>/<name pattern> rules given by the user
+func newSkipper(kopyOptions *options.KopyOptions, apiResources []koperator.APIResource) (*koperator.Skipper, error) {
+	var rules []koperator.Reference
+	for _, skip := range kopyOptions.Skips {
+		rule, err := koperator.ParseReference(skip, apiResources)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --skip: %v", err)
 		}
+		rules = append(rules, rule)
 	}
+	return koperator.NewSkipper(!kopyOptions.NoDefaultSkips, rules)
+}
 
-	if len(*kResource.Roles) > 0 {
-		for _, v := range *kResource.Roles {
-			koperator.ManipulateResource(&v)
-			_, err := kOpts.CreateRole(&v)
-			if err != nil {
-				return err
-			}
-			log.Infof("Copied resource %v of type Role", v.GetName())
+// skip leaves out the objects the skipper rejects, reporting them as skipped. Objects
+// owned by a controller, such as the Jobs of a CronJob, are created again by it in
+// the destination
+func (kResource *kopyResources) skip(skipper *koperator.Skipper) {
+	kResource.Excluded = map[koperator.Reference]bool{}
+	for _, v := range kResource.objects() {
+		if reason := skipper.Skip(v); reason != "" {
+			kResource.Excluded[koperator.ReferenceOf(v)] = true
+			kResource.Skipped = append(kResource.Skipped, newSkipResult(v, reason))
 		}
 	}
+}
 
-	if len(*kResource.RoleBindings) > 0 {
-		for _, v := range *kResource.RoleBindings {
-			koperator.ManipulateResource(&v)
-			_, err := kOpts.CreateRBinding(&v)
-			if err != nil {
-				return err
-			}
-			log.Infof("Copied resource %v of type RoleBinding", v.GetName())
+// allowsTyped tells if the filter allows a resource read through a typed client.
+// Ingresses are matched in whichever group the source serves them from
+func allowsTyped(filter *koperator.KindFilter, apiResources []koperator.APIResource, resource schema.GroupResource) bool {
+	candidates := []schema.GroupResource{resource}
+	if resource == networkingv1.Resource("ingresses") {
+		candidates = nil
+		for _, gv := range koperator.IngressVersions {
+			candidates = append(candidates, gv.WithResource("ingresses").GroupResource())
 		}
 	}
 
-	if len(*kResource.Secrets) > 0 {
-		for _, v := range *kResource.Secrets {
-			koperator.ManipulateResource(&v)
-			_, err := kOpts.CreateSecret(&v)
-			if err != nil {
-				return err
+	for _, candidate := range candidates {
+		for _, r := range apiResources {
+			if r.GroupResource() == candidate {
+				return filter.Allows(r)
 			}
-			log.Infof("Copied resource %v of type Secret", v.GetName())
 		}
 	}
+	return filter.Allows(koperator.APIResource{GroupVersionResource: resource.WithVersion("")})
+}
 
-	if len(*kResource.Services) > 0 {
-		for _, v := range *kResource.Services {
-			koperator.ManipulateResource(&v)
-			_, err := kOpts.CreateSVC(&v)
-			if err != nil {
-				return err
+// unlistable tells if a list error means the kind can't be read from the source,
+// as opposed to the source failing
+func unlistable(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err)
+}
+
+// getUnstructuredResources returns the objects of all the other namespaced resources
+// served by the cluster which pass the filters. The kinds which can't be listed are
+// left out and returned as skipped
+func getUnstructuredResources(kOpts *koperator.Options, apiResources []koperator.APIResource, filter *koperator.KindFilter, names *koperator.NameFilter, workers int) ([]unstructured.Unstructured, []objectResult, error) {
+	var resources []koperator.APIResource
+	for _, r := range apiResources {
+		if typedResources[r.GroupResource()] || ignoredResources[r.GroupResource()] || !filter.Allows(r) {
+			continue
+		}
+		resources = append(resources, r)
+	}
+
+	lists := make([]*unstructured.UnstructuredList, len(resources))
+	errs := make([]error, len(resources))
+	forEach(workers, len(resources), func(i int) {
+		lists[i], errs[i] = kOpts.GetUnstructured(resources[i])
+	})
+
+	var (
+		result  []unstructured.Unstructured
+		skipped []objectResult
+	)
+	for i, list := range lists {
+		switch {
+		case errs[i] == nil:
+		case unlistable(errs[i]):
+			skipped = append(skipped, newUnlistedResult(resources[i].Kind, kOpts.Namespace(), errs[i]))
+			continue
+		default:
+			return nil, nil, errs[i]
+		}
+
+		for _, v := range list.Items {
+			if names.Allows(v.GetName()) {
+				result = append(result, v)
 			}
-			log.Infof("Copied resource %v of type Service", v.GetName())
 		}
 	}
 
-	if len(*kResource.Ingresses) > 0 {
-		for _, v := range *kResource.Ingresses {
-			koperator.ManipulateResource(&v)
-			_, err := kOpts.CreateIngress(&v)
+	return result, skipped, nil
+}
+
+// newKopyResources returns an empty set of resources
+func newKopyResources() *kopyResources {
+	return &kopyResources{
+		Deployments:  &[]appv1.Deployment{},
+		StatefulSets: &[]appv1.StatefulSet{},
+		ConfigMaps:   &[]corev1.ConfigMap{},
+		Roles:        &[]rbacv1.Role{},
+		RoleBindings: &[]rbacv1.RoleBinding{},
+		Secrets:      &[]corev1.Secret{},
+		Services:     &[]corev1.Service{},
+		Ingresses:    &[]networkingv1.Ingress{},
+		PVCs:         &[]corev1.PersistentVolumeClaim{},
+		Jobs:         &[]batchv1.Job{},
+		Unstructured: &[]unstructured.Unstructured{},
+	}
+}
+
+// add decodes an object into the typed resources the typed clients handle,
+// any other object is kept as unstructured
+func (kResource *kopyResources) add(obj *unstructured.Unstructured) (err error) {
+	from := runtime.DefaultUnstructuredConverter.FromUnstructured
+	switch obj.GroupVersionKind() {
+	case appv1.SchemeGroupVersion.WithKind("Deployment"):
+		var v appv1.Deployment
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.Deployments = append(*kResource.Deployments, v)
+		}
+	case appv1.SchemeGroupVersion.WithKind("StatefulSet"):
+		var v appv1.StatefulSet
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.StatefulSets = append(*kResource.StatefulSets, v)
+		}
+	case corev1.SchemeGroupVersion.WithKind("ConfigMap"):
+		var v corev1.ConfigMap
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.ConfigMaps = append(*kResource.ConfigMaps, v)
+		}
+	case rbacv1.SchemeGroupVersion.WithKind("Role"):
+		var v rbacv1.Role
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.Roles = append(*kResource.Roles, v)
+		}
+	case rbacv1.SchemeGroupVersion.WithKind("RoleBinding"):
+		var v rbacv1.RoleBinding
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.RoleBindings = append(*kResource.RoleBindings, v)
+		}
+	case corev1.SchemeGroupVersion.WithKind("Secret"):
+		var v corev1.Secret
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.Secrets = append(*kResource.Secrets, v)
+		}
+	case corev1.SchemeGroupVersion.WithKind("Service"):
+		var v corev1.Service
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.Services = append(*kResource.Services, v)
+		}
+	case networkingv1.SchemeGroupVersion.WithKind("Ingress"):
+		var v networkingv1.Ingress
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.Ingresses = append(*kResource.Ingresses, v)
+		}
+	case netv1beta1.SchemeGroupVersion.WithKind("Ingress"):
+		var v netv1beta1.Ingress
+		if err = from(obj.Object, &v); err == nil {
+			err = kResource.addIngress(&v)
+		}
+	case extv1beta1.SchemeGroupVersion.WithKind("Ingress"):
+		var v extv1beta1.Ingress
+		if err = from(obj.Object, &v); err == nil {
+			err = kResource.addIngress(&v)
+		}
+	case corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"):
+		var v corev1.PersistentVolumeClaim
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.PVCs = append(*kResource.PVCs, v)
+		}
+	case batchv1.SchemeGroupVersion.WithKind("Job"):
+		var v batchv1.Job
+		if err = from(obj.Object, &v); err == nil {
+			*kResource.Jobs = append(*kResource.Jobs, v)
+		}
+	default:
+		*kResource.Unstructured = append(*kResource.Unstructured, *obj)
+	}
+	return
+}
+
+// addIngress adds an Ingress of an older version, converted to networking.k8s.io/v1
+func (kResource *kopyResources) addIngress(x interface{}) error {
+	ingress, err := koperator.ConvertIngress(x, networkingv1.SchemeGroupVersion)
+	if err != nil {
+		return err
+	}
+	*kResource.Ingresses = append(*kResource.Ingresses, *ingress.(*networkingv1.Ingress))
+	return nil
+}
+
+// objects returns the resources which are part of the copy
+func (kResource *kopyResources) objects() []interface{} {
+	var kept []interface{}
+	for _, v := range kResource.named() {
+		ref := koperator.ReferenceOf(v)
+		if (kResource.Only != nil && !kResource.Only[ref]) || kResource.Excluded[ref] {
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// named returns all the resources which pass the name filters, along with the
+// ones left out of the copy
+func (kResource *kopyResources) named() []interface{} {
+	var result []interface{}
+	for i := range *kResource.Deployments {
+		result = append(result, &(*kResource.Deployments)[i])
+	}
+	for i := range *kResource.StatefulSets {
+		result = append(result, &(*kResource.StatefulSets)[i])
+	}
+	for i := range *kResource.ConfigMaps {
+		result = append(result, &(*kResource.ConfigMaps)[i])
+	}
+	for i := range *kResource.Roles {
+		result = append(result, &(*kResource.Roles)[i])
+	}
+	for i := range *kResource.RoleBindings {
+		result = append(result, &(*kResource.RoleBindings)[i])
+	}
+	for i := range *kResource.Secrets {
+		result = append(result, &(*kResource.Secrets)[i])
+	}
+	for i := range *kResource.Services {
+		result = append(result, &(*kResource.Services)[i])
+	}
+	for i := range *kResource.Ingresses {
+		result = append(result, &(*kResource.Ingresses)[i])
+	}
+	for i := range *kResource.PVCs {
+		result = append(result, &(*kResource.PVCs)[i])
+	}
+	for i := range *kResource.Jobs {
+		result = append(result, &(*kResource.Jobs)[i])
+	}
+	for i := range *kResource.Unstructured {
+		result = append(result, &(*kResource.Unstructured)[i])
+	}
+
+	var kept []interface{}
+	for _, v := range result {
+		if obj, ok := v.(metav1.Object); ok && !kResource.Names.Allows(obj.GetName()) {
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// tiers returns the resources grouped in the order they have to be created in,
+// warning about references to objects which are not copied
+func (kResource *kopyResources) tiers(sourceNS string) [][]interface{} {
+	tiers, dangling := koperator.Order(kResource.objects())
+	for _, d := range dangling {
+		log.Warnf("%v in namespace %v references %v which is not copied", d.Object, sourceNS, d.Reference)
+	}
+	return tiers
+}
+
+// ordered returns the resources in the order they have to be created in
+func (kResource *kopyResources) ordered(sourceNS string) []interface{} {
+	var result []interface{}
+	for _, tier := range kResource.tiers(sourceNS) {
+		result = append(result, tier...)
+	}
+	return result
+}
+
+// createResources creates the resources in the destination, existing objects are
+// handled as per the mode. In dry-run every object is validated by the server and
+// the outcome of all of them is returned, otherwise it stops at the first failure
+func createResources(kOpts *koperator.Options, kResource *kopyResources, sourceNS string, kopyOptions *options.KopyOptions, tx *transaction) ([]objectResult, error) {
+	results := append([]objectResult{}, kResource.Skipped...)
+	for _, tier := range kResource.tiers(sourceNS) {
+		if err := tx.checkInterrupted(); err != nil {
+			return results, err
+		}
+
+		// Objects which can't be converted fail like the ones the destination rejects
+		var firstErr error
+		var served []interface{}
+		for _, v := range tier {
+			koperator.ManipulateResource(v)
+			koperator.SwitchNamespace(v, sourceNS, kOpts.Namespace())
+			koperator.RewriteStorageClass(v, kopyOptions.StorageClasses)
+
+			converted, err := kOpts.ServedVersion(v)
+			if koperator.IsNotServed(err) {
+				results = append(results, newSkipResult(v, "no equivalent in destination"))
+				continue
+			}
 			if err != nil {
-				return err
+				result := newObjectResult(v, actionFail, err)
+				results = append(results, result)
+				if kopyOptions.DryRun {
+					continue
+				}
+				if kopyOptions.ContinueOnError {
+					log.Errorf("Copying resource %v of type %v failed (%v): %v", result.Name, result.Kind, result.Action, err)
+				} else if firstErr == nil {
+					firstErr = err
+				}
+				continue
 			}
-			log.Infof("Copied resource %v of type Ingress", v.GetName())
+			served = append(served, converted)
+		}
+		tier = served
+
+		// Resources of a tier don't depend on each other and are copied concurrently,
+		// their outcome is logged in order once the whole tier is done
+		tierResults := make([]objectResult, len(tier))
+		created := make([]bool, len(tier))
+		errs := make([]error, len(tier))
+		forEach(kopyOptions.Parallel, len(tier), func(i int) {
+			start := time.Now()
+			tierResults[i], created[i], errs[i] = copyResource(kOpts, tier[i], kopyOptions)
+			tierResults[i].Duration = time.Since(start)
+		})
+
+		var createdObjects []interface{}
+		for i, result := range tierResults {
+			if created[i] && !kopyOptions.DryRun {
+				createdObjects = append(createdObjects, tier[i])
+			}
+
+			results = append(results, result)
+			if kopyOptions.DryRun {
+				continue
+			}
+			if errs[i] != nil {
+				if kopyOptions.ContinueOnError {
+					log.Errorf("Copying resource %v of type %v failed (%v): %v", result.Name, result.Kind, result.Action, errs[i])
+				} else if firstErr == nil {
+					firstErr = errs[i]
+				}
+				continue
+			}
+			log.Infof("Copied resource %v of type %v (%v)", result.Name, result.Kind, result.Action)
+		}
+		if err := tx.record(createdObjects...); err != nil {
+			return results, err
+		}
+		if firstErr != nil {
+			return results, firstErr
 		}
 	}
 
-	return nil
+	return results, nil
+}
+
+// copyResource creates an object in the destination, when it already exists it
+// is either left as is or updated as per the mode
+func copyResource(kOpts *koperator.Options, x interface{}, kopyOptions *options.KopyOptions) (result objectResult, created bool, err error) {
+	if kopyOptions.ServerSide {
+		return applyResource(kOpts, x, kopyOptions.Mode)
+	}
+
+	err = kOpts.CreateResource(x)
+	if !apierrors.IsAlreadyExists(err) {
+		return newObjectResult(x, actionCreate, err), err == nil, err
+	}
+
+	switch kopyOptions.Mode {
+	case options.ModeMerge:
+		return newSkipResult(x, "exists in destination"), false, nil
+	case options.ModeOverwrite:
+		err = kOpts.UpdateResource(x)
+		return newObjectResult(x, actionUpdate, err), false, err
+	}
+	return newObjectResult(x, actionCreate, err), false, err
+}
+
+// applyResource applies the object, it is reported as created when it didn't
+// exist in the destination before
+func applyResource(kOpts *koperator.Options, x interface{}, mode string) (result objectResult, created bool, err error) {
+	exists, err := kOpts.ExistsResource(x)
+	if err != nil {
+		return newObjectResult(x, actionApply, err), false, err
+	}
+	if exists && mode == options.ModeMerge {
+		return newSkipResult(x, "exists in destination"), false, nil
+	}
+
+	err = kOpts.ApplyResource(x)
+	return newObjectResult(x, actionApply, err), !exists && err == nil, err
+}
+
+// planResources lists the resources which would be created in the destination
+// without calling the server
+func planResources(kOpts *koperator.Options, kResource *kopyResources, sourceNS string, kopyOptions *options.KopyOptions) []objectResult {
+	action := actionCreate
+	if kopyOptions.ServerSide {
+		action = actionApply
+	}
+
+	results := append([]objectResult{}, kResource.Skipped...)
+	for _, v := range kResource.ordered(sourceNS) {
+		koperator.ManipulateResource(v)
+		koperator.SwitchNamespace(v, sourceNS, kOpts.Namespace())
+		koperator.RewriteStorageClass(v, kopyOptions.StorageClasses)
+		results = append(results, newObjectResult(v, action, nil))
+	}
+	return results
 }
 
 func isValidNS(kOpts *koperator.Options) bool {
@@ -226,3 +993,21 @@ func isValidNS(kOpts *koperator.Options) bool {
 	}
 	return true
 }
+
+// getTargetNS returns the namespace name resources of the given namespace are copied into
+func getTargetNS(kopyOptions *options.KopyOptions, namespace string) string {
+	if kopyOptions.TargetNamespace != "" {
+		return kopyOptions.TargetNamespace
+	}
+	if kopyOptions.AutoTargetNS {
+		return koperator.GenerateNSName(namespace, currentUser())
+	}
+	return namespace
+}
+
+func currentUser() string {
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return os.Getenv("USERNAME") // windows
+}
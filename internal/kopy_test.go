@@ -0,0 +1,194 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tejabeta/kopy/internal/options"
+	"github.com/tejabeta/kopy/pkg/koperator"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+func TestAllowsTypedIngress(t *testing.T) {
+
+	// A source serving Ingresses only from extensions/v1beta1
+	apiResources := []koperator.APIResource{{
+		GroupVersionResource: extv1beta1.SchemeGroupVersion.WithResource("ingresses"),
+		Kind:                 "Ingress",
+		SingularName:         "ingress",
+		ShortNames:           []string{"ing"},
+	}}
+	ingresses := networkingv1.Resource("ingresses")
+
+	testCases := []struct {
+		include []string
+		exclude []string
+		allowed bool
+	}{
+		{nil, nil, true},
+		{nil, []string{"ing"}, false},
+		{nil, []string{"ingress"}, false},
+		{[]string{"ing"}, nil, true},
+		{[]string{"deploy"}, nil, false},
+	}
+	for _, tc := range testCases {
+		filter := koperator.NewKindFilter(tc.include, tc.exclude)
+		if allowed := allowsTyped(filter, apiResources, ingresses); allowed != tc.allowed {
+			t.Errorf("Error while filtering Ingresses with include %v and exclude %v, got %v expected %v", tc.include, tc.exclude, allowed, tc.allowed)
+		}
+	}
+
+}
+
+// newListServer serves ConfigMaps, forbids listing Secrets and answers the lists of
+// PodTemplates with the given status
+func newListServer(podTemplatesStatus int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+		case "/apis":
+			w.Write([]byte(`{"kind":"APIGroupList","groups":[]}`))
+		case "/api/v1":
+			w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+				`{"name":"configmaps","kind":"ConfigMap","namespaced":true,"verbs":["create","list"]},` +
+				`{"name":"secrets","kind":"Secret","namespaced":true,"verbs":["create","list"]},` +
+				`{"name":"podtemplates","kind":"PodTemplate","namespaced":true,"verbs":["create","list"]}]}`))
+		case "/api/v1/namespaces/unit-test-ns/configmaps":
+			w.Write([]byte(`{"kind":"ConfigMapList","apiVersion":"v1","items":[{"metadata":{"name":"unit-test-cm","namespace":"unit-test-ns"}}]}`))
+		case "/api/v1/namespaces/unit-test-ns/secrets":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","message":"secrets is forbidden","code":403}`))
+		case "/api/v1/namespaces/unit-test-ns/podtemplates":
+			w.WriteHeader(podTemplatesStatus)
+			if podTemplatesStatus == http.StatusOK {
+				w.Write([]byte(`{"kind":"PodTemplateList","apiVersion":"v1","items":[]}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGetResourcesUnlistable(t *testing.T) {
+
+	testCases := []struct {
+		status  int
+		skipped []string
+		fails   bool
+	}{
+		{http.StatusOK, []string{"Secret"}, false},
+		{http.StatusMethodNotAllowed, []string{"Secret", "PodTemplate"}, false},
+		{http.StatusNotFound, []string{"Secret", "PodTemplate"}, false},
+		{http.StatusInternalServerError, nil, true},
+	}
+	for _, tc := range testCases {
+		server := newListServer(tc.status)
+		kOpts, err := koperator.GetOpts(&rest.Config{Host: server.URL}, "unit-test-ns")
+		if err != nil {
+			t.Fatal(err)
+		}
+		kopyOptions := &options.KopyOptions{IncludeKinds: []string{"configmaps", "secrets", "podtemplates"}, NoDefaultSkips: true}
+
+		resources, err := getResources(kOpts, kopyOptions)
+		server.Close()
+		if tc.fails {
+			if err == nil {
+				t.Errorf("Error while listing PodTemplates with status %v, expected an error", tc.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Error while listing PodTemplates with status %v, got %v", tc.status, err)
+			continue
+		}
+
+		var skipped []string
+		for _, r := range resources.Skipped {
+			if r.Action != actionSkip || r.Namespace != "unit-test-ns" {
+				t.Errorf("Error while reporting %v, got %+v", r.Kind, r)
+			}
+			skipped = append(skipped, r.Kind)
+		}
+		if !reflect.DeepEqual(skipped, tc.skipped) {
+			t.Errorf("Error while listing PodTemplates with status %v, got skipped %v expected %v", tc.status, skipped, tc.skipped)
+		}
+		if len(*resources.ConfigMaps) != 1 {
+			t.Errorf("Error while listing ConfigMaps, got %v", *resources.ConfigMaps)
+		}
+	}
+
+}
+
+func TestKeepClosureSkipped(t *testing.T) {
+
+	apiResources := []koperator.APIResource{{
+		GroupVersionResource: appv1.SchemeGroupVersion.WithResource("deployments"),
+		Kind:                 "Deployment",
+		SingularName:         "deployment",
+		ShortNames:           []string{"deploy"},
+	}}
+
+	deploy := appv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-app", Namespace: "unit-test-ns"},
+	}
+	sa := unstructured.Unstructured{}
+	sa.SetAPIVersion("v1")
+	sa.SetKind("ServiceAccount")
+	sa.SetNamespace("unit-test-ns")
+	sa.SetName("default")
+	release := corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: "sh.helm.release.v1.unit-test.v1", Namespace: "unit-test-ns"},
+	}
+
+	kResource := newKopyResources()
+	*kResource.Deployments = append(*kResource.Deployments, deploy)
+	*kResource.Unstructured = append(*kResource.Unstructured, sa)
+	*kResource.Secrets = append(*kResource.Secrets, release)
+	kResource.Skipped = []objectResult{{Kind: "PodTemplate", Namespace: "unit-test-ns", Action: actionSkip}}
+
+	skipper, err := koperator.NewSkipper(true, []koperator.Reference{{Kind: "Secret", Name: "sh.helm.release.*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kResource.skip(skipper)
+	if err := kResource.keepClosure([]string{"deploy/unit-test-app"}, apiResources); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default ServiceAccount of the Deployment is skipped, the Helm release isn't
+	// part of the copy at all
+	var skipped []string
+	for _, r := range kResource.Skipped {
+		skipped = append(skipped, r.Kind+"/"+r.Name)
+	}
+	if expected := []string{"PodTemplate/", "ServiceAccount/default"}; !reflect.DeepEqual(skipped, expected) {
+		t.Errorf("Error while keeping the closure, got skipped %v expected %v", skipped, expected)
+	}
+
+}
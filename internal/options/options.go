@@ -14,36 +14,196 @@ limitations under the License.
 package options
 
 import (
-	"github.com/tejabeta/kopy/internal/context"
+	"os"
 
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
+// InCluster is the name of the context of the cluster kopy runs in, authenticated
+// with the service account of its pod
+const InCluster = "in-cluster"
+
+// Modes of copying into a destination namespace which already exists
+const (
+	// ModeCreateOnly refuses to copy into an existing namespace
+	ModeCreateOnly = "create-only"
+	// ModeMerge creates the missing objects and leaves the existing ones as is
+	ModeMerge = "merge"
+	// ModeOverwrite creates the missing objects and updates the existing ones
+	ModeOverwrite = "overwrite"
+	// ModeReplace deletes the namespace and creates it again
+	ModeReplace = "replace"
+)
+
+// Modes lists all the supported modes
+var Modes = []string{ModeCreateOnly, ModeMerge, ModeOverwrite, ModeReplace}
+
+// Formats of the run report
+const (
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// Outputs lists all the supported report formats
+var Outputs = []string{OutputJSON, OutputYAML}
+
 type KopyOptions struct {
-	Namespace          string
+	Namespaces         []string
+	NamespaceSelector  string
+	TargetNamespace    string
+	AutoTargetNS       bool
 	AllResource        bool
+	IncludeKinds       []string
+	ExcludeKinds       []string
+	IncludeJobs        bool
+	LabelSelector      string
+	FieldSelector      string
+	IncludeNames       []string
+	ExcludeNames       []string
+	Roots              []string
+	NoDefaultSkips     bool
+	Skips              []string
+	StorageClasses     map[string]string
+	DryRun             bool
+	Mode               string
+	ServerSide         bool
+	ForceConflicts     bool
+	Parallel           int
+	Atomic             bool
+	ContinueOnError    bool
+	Output             string
+	KeepNamespace      bool
 	SourceContext      *rest.Config
 	DestinationContext *rest.Config
+	// Where the configs of the clusters are loaded from
+	Source      Cluster
+	Destination Cluster
 }
 
-func GetKopyOptions(sourceCName string, destCName string) (*KopyOptions, error) {
+// Cluster tells where to load the config of a cluster from, with the flags of
+// kubectl: the kubeconfig files, the context, the server, the impersonated user...
+type Cluster struct {
+	*genericclioptions.ConfigFlags
+	// TokenFile is the path of the file holding the bearer token, which is read
+	// again when it's rotated
+	TokenFile string
+}
 
-	var sContext, dContext *rest.Config
-	var err error
+// NewCluster returns a cluster loaded from the current context, with the loading
+// rules of kubectl: the files listed by the KUBECONFIG env variable merged
+// together, or ~/.kube/config
+func NewCluster() Cluster {
+	flags := genericclioptions.NewConfigFlags(false)
+	// Namespaces are picked by the flags of kopy and discovery isn't cached
+	flags.Namespace = nil
+	flags.CacheDir = nil
+	return Cluster{ConfigFlags: flags}
+}
 
-	if sourceCName == "" {
-		sContext, err = context.GetContext()
-		if err != nil {
-			return nil, err
-		}
+// Name returns the name of the cluster for the reports, its server or its context,
+// or nothing for the side a command doesn't talk to. The current context of the
+// kubeconfig is used when no context is given
+func (c Cluster) Name() string {
+	if c.ConfigFlags == nil {
+		return ""
+	}
+	if *c.APIServer != "" {
+		return *c.APIServer
+	}
+	if *c.Context != "" {
+		return *c.Context
+	}
+
+	raw, err := c.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return ""
+	}
+	return raw.CurrentContext
+}
+
+// config builds the config of the cluster
+func (c Cluster) config() (*rest.Config, error) {
+	var config *rest.Config
+	var err error
+	if *c.Context == InCluster {
+		config, err = c.inClusterConfig()
 	} else {
-		sContext, err = context.SwitchContext(sourceCName)
-		if err != nil {
+		config, err = c.ToRESTConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.TokenFile != "" {
+		if _, err := os.Stat(c.TokenFile); err != nil {
 			return nil, err
 		}
+		config.BearerToken = ""
+		config.BearerTokenFile = c.TokenFile
 	}
+	return config, nil
+}
 
-	dContext, err = context.SwitchContext(destCName)
+// inClusterConfig returns the config of the cluster kopy runs in. There is no
+// kubeconfig to override, only the impersonation and the timeout apply
+func (c Cluster) inClusterConfig() (*rest.Config, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Timeout, err = clientcmd.ParseTimeout(*c.Timeout); err != nil {
+		return nil, err
+	}
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: *c.Impersonate,
+		Groups:   *c.ImpersonateGroup,
+	}
+	return config, nil
+}
+
+// GetSourceOptions builds the options with only the source context, for the
+// commands which don't write into a destination
+func GetSourceOptions(source Cluster) (*KopyOptions, error) {
+	sContext, err := source.config()
+	if err != nil {
+		return nil, err
+	}
+
+	return &KopyOptions{
+		SourceContext: sContext,
+		Source:        source,
+		Destination:   NewCluster(),
+	}, nil
+}
+
+// GetDestinationOptions builds the options with only the destination context,
+// for the commands which don't read from a source context
+func GetDestinationOptions(destination Cluster) (*KopyOptions, error) {
+	dContext, err := destination.config()
+	if err != nil {
+		return nil, err
+	}
+
+	return &KopyOptions{
+		DestinationContext: dContext,
+		Source:             NewCluster(),
+		Destination:        destination,
+	}, nil
+}
+
+func GetKopyOptions(source Cluster, destination Cluster) (*KopyOptions, error) {
+
+	var dContext *rest.Config
+
+	sContext, err := source.config()
+	if err != nil {
+		return nil, err
+	}
+
+	dContext, err = destination.config()
 	if err != nil {
 		return nil, err
 	}
@@ -51,5 +211,29 @@ func GetKopyOptions(sourceCName string, destCName string) (*KopyOptions, error)
 	return &KopyOptions{
 		SourceContext:      sContext,
 		DestinationContext: dContext,
+		Source:             source,
+		Destination:        destination,
 	}, err
 }
+
+// SetConcurrency sets the number of parallel workers and the rate limits of the
+// clients, which scale with the number of workers when left to zero
+func (o *KopyOptions) SetConcurrency(parallel int, qps float32, burst int) {
+	if parallel < 1 {
+		parallel = 1
+	}
+	if qps == 0 {
+		qps = rest.DefaultQPS * float32(parallel)
+	}
+	if burst == 0 {
+		burst = rest.DefaultBurst * parallel
+	}
+
+	o.Parallel = parallel
+	for _, config := range []*rest.Config{o.SourceContext, o.DestinationContext} {
+		if config != nil {
+			config.QPS = qps
+			config.Burst = burst
+		}
+	}
+}
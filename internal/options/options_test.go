@@ -0,0 +1,54 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestClusterName(t *testing.T) {
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	content := "apiVersion: v1\nkind: Config\ncurrent-context: unit-test-current\n" +
+		"contexts:\n- name: unit-test-current\n  context:\n    cluster: unit-test-cluster\n" +
+		"clusters:\n- name: unit-test-cluster\n  cluster:\n    server: https://unit-test\n"
+	if err := ioutil.WriteFile(kubeconfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		context  string
+		server   string
+		expected string
+	}{
+		{"", "", "unit-test-current"},
+		{"unit-test-other", "", "unit-test-other"},
+		{"", "https://unit-test-server", "https://unit-test-server"},
+	}
+	for _, tc := range testCases {
+		c := NewCluster()
+		*c.KubeConfig, *c.Context, *c.APIServer = kubeconfig, tc.context, tc.server
+		if name := c.Name(); name != tc.expected {
+			t.Errorf("Error while naming the cluster of context %q and server %q, got %v expected %v", tc.context, tc.server, name, tc.expected)
+		}
+	}
+
+	if name := (Cluster{}).Name(); name != "" {
+		t.Errorf("Error while naming an unused cluster, got %v", name)
+	}
+
+}
@@ -0,0 +1,313 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tejabeta/kopy/internal/options"
+	"github.com/tejabeta/kopy/pkg/koperator"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Actions kopy takes, or would take in dry-run, on an object
+const (
+	actionCreate    = "create"
+	actionUpdate    = "update"
+	actionApply     = "apply"
+	actionDelete    = "delete"
+	actionSkip      = "skip"
+	actionConflict  = "conflict"
+	actionForbidden = "forbidden"
+	actionInvalid   = "invalid"
+	actionFail      = "fail"
+)
+
+// Exit codes of a run
+const (
+	// ExitSuccess is returned when every object got copied
+	ExitSuccess = 0
+	// ExitFailure is returned when nothing could be copied
+	ExitFailure = 1
+	// ExitPartial is returned when some of the objects could not be copied
+	ExitPartial = 2
+)
+
+// RunError is returned by a run which failed to copy some of the namespaces
+type RunError struct {
+	Failed int
+	Total  int
+	// Partial tells if some objects got copied nevertheless
+	Partial bool
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("%v of %v namespaces failed to copy", e.Failed, e.Total)
+}
+
+// ExitCode returns the exit code matching the error a run ended with
+func ExitCode(err error) int {
+	var runErr *RunError
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.As(err, &runErr) && runErr.Partial:
+		return ExitPartial
+	}
+	return ExitFailure
+}
+
+// objectResult holds the outcome of copying a single object
+type objectResult struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Action    string
+	// Reason is the reason of a skip, or the reason of the API error
+	Reason   string
+	Message  string
+	Duration time.Duration
+	// ref identifies the object, it is left out of the reports
+	ref koperator.Reference
+}
+
+// newObjectResult records the outcome of the given action on an object
+func newObjectResult(x interface{}, action string, err error) objectResult {
+	result := objectResult{Kind: koperator.KindOf(x), Action: action, ref: koperator.ReferenceOf(x)}
+	if obj, ok := x.(metav1.Object); ok {
+		result.Name, result.Namespace = obj.GetName(), obj.GetNamespace()
+	}
+
+	switch {
+	case err == nil:
+		return result
+	case apierrors.IsAlreadyExists(err), apierrors.IsConflict(err):
+		result.Action = actionConflict
+	case apierrors.IsForbidden(err):
+		result.Action = actionForbidden
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		result.Action = actionInvalid
+	default:
+		result.Action = actionFail
+	}
+	result.Reason, result.Message = string(apierrors.ReasonForError(err)), err.Error()
+	return result
+}
+
+// newSkipResult records an object which is left out of the copy
+func newSkipResult(x interface{}, reason string) objectResult {
+	result := newObjectResult(x, actionSkip, nil)
+	result.Reason = reason
+	return result
+}
+
+// newUnlistedResult records a kind which is left out of the copy as it can't be listed
+func newUnlistedResult(kind, namespace string, err error) objectResult {
+	log.Warnf("%v can't be listed in namespace %v, skipping: %v", kind, namespace, err)
+	return objectResult{
+		Kind:      kind,
+		Namespace: namespace,
+		Action:    actionSkip,
+		Reason:    "can't be listed",
+		Message:   err.Error(),
+	}
+}
+
+// failed tells if the object could not be or would not be copied
+func (r objectResult) failed() bool {
+	switch r.Action {
+	case actionConflict, actionForbidden, actionInvalid, actionFail:
+		return true
+	}
+	return false
+}
+
+// copied tells if the object got or would get written into the destination
+func (r objectResult) copied() bool {
+	switch r.Action {
+	case actionCreate, actionUpdate, actionApply:
+		return true
+	}
+	return false
+}
+
+// printPlan writes the objects and their actions as a table
+func printPlan(w io.Writer, results []objectResult) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tNAMESPACE\tACTION\tREASON\tMESSAGE")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", r.Kind, orDash(r.Name), orDash(r.Namespace), r.Action, orDash(r.Reason), r.Message)
+	}
+	tw.Flush()
+}
+
+// printSummary writes the number of objects per outcome of every namespace as a table
+func printSummary(w io.Writer, results []nsResult, dryRun bool) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tTARGET\tCREATED\tUPDATED\tAPPLIED\tSKIPPED\tFAILED\tSTATUS")
+	for _, r := range results {
+		counts := map[string]int{}
+		failed := 0
+		for _, o := range r.Objects {
+			counts[o.Action]++
+			if o.failed() {
+				failed++
+			}
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", r.Namespace, r.TargetNS,
+			counts[actionCreate], counts[actionUpdate], counts[actionApply], counts[actionSkip], failed, r.status(dryRun))
+	}
+	tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// runReport is the machine-readable outcome of a run
+type runReport struct {
+	RunID              string            `json:"runId,omitempty"`
+	SourceContext      string            `json:"sourceContext,omitempty"`
+	DestinationContext string            `json:"destinationContext,omitempty"`
+	DryRun             bool              `json:"dryRun"`
+	StartedAt          time.Time         `json:"startedAt"`
+	Duration           string            `json:"duration"`
+	Status             string            `json:"status"`
+	ExitCode           int               `json:"exitCode"`
+	Error              string            `json:"error,omitempty"`
+	Namespaces         []namespaceReport `json:"namespaces"`
+}
+
+// namespaceReport is the outcome of a namespace in the run report
+type namespaceReport struct {
+	Namespace       string         `json:"namespace"`
+	TargetNamespace string         `json:"targetNamespace"`
+	Status          string         `json:"status"`
+	Error           string         `json:"error,omitempty"`
+	Duration        string         `json:"duration"`
+	Objects         []objectReport `json:"objects"`
+}
+
+// objectReport is the outcome of an object in the run report
+type objectReport struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Action    string `json:"action"`
+	Reason    string `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Duration  string `json:"duration,omitempty"`
+}
+
+// Statuses of a run
+var runStatuses = map[int]string{
+	ExitSuccess: "success",
+	ExitPartial: "partial",
+	ExitFailure: "failed",
+}
+
+// writeReport writes the report of the run to stdout when an output format is
+// requested, and returns the error the run ended with
+func writeReport(kopyOptions *options.KopyOptions, start time.Time, tx *transaction, results []nsResult, err error) error {
+	if kopyOptions.Output == "" {
+		return err
+	}
+
+	report := runReport{
+		SourceContext:      kopyOptions.Source.Name(),
+		DestinationContext: kopyOptions.Destination.Name(),
+		DryRun:             kopyOptions.DryRun,
+		StartedAt:          start,
+		Duration:           formatDuration(time.Since(start)),
+		ExitCode:           ExitCode(err),
+		Namespaces:         []namespaceReport{},
+	}
+	report.Status = runStatuses[report.ExitCode]
+	if tx != nil {
+		report.RunID = tx.journal.RunID
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	for _, r := range results {
+		ns := namespaceReport{
+			Namespace:       r.Namespace,
+			TargetNamespace: r.TargetNS,
+			Status:          r.status(kopyOptions.DryRun),
+			Duration:        formatDuration(r.Duration),
+			Objects:         []objectReport{},
+		}
+		if r.Err != nil {
+			ns.Error = r.Err.Error()
+		}
+		for _, o := range r.Objects {
+			ns.Objects = append(ns.Objects, objectReport{
+				Kind:      o.Kind,
+				Name:      o.Name,
+				Namespace: o.Namespace,
+				Action:    o.Action,
+				Reason:    o.Reason,
+				Error:     o.Message,
+				Duration:  formatDuration(o.Duration),
+			})
+		}
+		report.Namespaces = append(report.Namespaces, ns)
+	}
+
+	var content []byte
+	var mErr error
+	switch kopyOptions.Output {
+	case options.OutputYAML:
+		content, mErr = yaml.Marshal(report)
+	default:
+		content, mErr = json.MarshalIndent(report, "", "  ")
+		content = append(content, '\n')
+	}
+	if mErr != nil {
+		log.Errorln("Writing the run report failed: ", mErr)
+		return err
+	}
+
+	os.Stdout.Write(content)
+	return err
+}
+
+// humanOutput returns where the plan and summary tables are written, stderr
+// when stdout is taken by the run report
+func humanOutput(kopyOptions *options.KopyOptions) io.Writer {
+	if kopyOptions.Output != "" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,102 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExitCode(t *testing.T) {
+
+	created := objectResult{Kind: "ConfigMap", Name: "unit-test-cm", Action: actionCreate}
+	failed := objectResult{Kind: "Secret", Name: "unit-test-secret", Action: actionFail, Message: "unit-test-error"}
+
+	testCases := []struct {
+		name     string
+		results  []nsResult
+		expected int
+	}{
+		{"success", []nsResult{
+			{Namespace: "unit-test-a", Objects: []objectResult{created}},
+			{Namespace: "unit-test-b", Objects: []objectResult{created}},
+		}, ExitSuccess},
+		{"full failure", []nsResult{
+			{Namespace: "unit-test-a", Objects: []objectResult{failed}, Err: errors.New("unit-test-error")},
+			{Namespace: "unit-test-b", Err: errors.New("unit-test-error")},
+		}, ExitFailure},
+		{"partial failure", []nsResult{
+			{Namespace: "unit-test-a", Objects: []objectResult{created}},
+			{Namespace: "unit-test-b", Objects: []objectResult{failed}, Err: errors.New("unit-test-error")},
+		}, ExitPartial},
+		// With --continue-on-error the namespace goes on past its failed objects
+		{"partial failure with continue-on-error", []nsResult{
+			{Namespace: "unit-test-a", Objects: []objectResult{created, failed}},
+		}, ExitPartial},
+	}
+	for _, tc := range testCases {
+		err := summarize(ioutil.Discard, tc.results, false)
+		if code := ExitCode(err); code != tc.expected {
+			t.Errorf("Error while computing the exit code of %v, got %v expected %v", tc.name, code, tc.expected)
+		}
+		if code := ExitCode(fmt.Errorf("unit-test: %w", err)); err != nil && code != tc.expected {
+			t.Errorf("Error while computing the exit code of wrapped %v, got %v expected %v", tc.name, code, tc.expected)
+		}
+	}
+
+	if code := ExitCode(errors.New("unit-test-error")); code != ExitFailure {
+		t.Errorf("Error while computing the exit code of a run which didn't start, got %v", code)
+	}
+
+}
+
+func TestNewObjectResult(t *testing.T) {
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-cm", Namespace: "unit-test-ns"}}
+	resource := corev1.Resource("configmaps")
+
+	testCases := []struct {
+		name   string
+		result objectResult
+		action string
+		reason metav1.StatusReason
+	}{
+		{"created", newObjectResult(cm, actionCreate, nil), actionCreate, ""},
+		{"existing", newObjectResult(cm, actionCreate, apierrors.NewAlreadyExists(resource, cm.Name)), actionConflict, metav1.StatusReasonAlreadyExists},
+		{"skipped", newSkipResult(cm, "exists in destination"), actionSkip, "exists in destination"},
+		{"forbidden", newObjectResult(cm, actionCreate, apierrors.NewForbidden(resource, cm.Name, errors.New("unit-test-error"))), actionForbidden, metav1.StatusReasonForbidden},
+		{"invalid", newObjectResult(cm, actionUpdate, apierrors.NewBadRequest("unit-test-error")), actionInvalid, metav1.StatusReasonBadRequest},
+		{"failed", newObjectResult(cm, actionCreate, errors.New("unit-test-error")), actionFail, metav1.StatusReasonUnknown},
+	}
+	for _, tc := range testCases {
+		r := tc.result
+		if r.Kind != "ConfigMap" || r.Name != cm.Name || r.Namespace != cm.Namespace {
+			t.Errorf("Error while recording %v object, got %+v", tc.name, r)
+		}
+		if r.Action != tc.action || r.Reason != string(tc.reason) {
+			t.Errorf("Error while recording %v object, got %v %q expected %v %q", tc.name, r.Action, r.Reason, tc.action, tc.reason)
+		}
+		if failed := tc.action != actionCreate && tc.action != actionSkip; r.failed() != failed {
+			t.Errorf("Error while recording %v object, got failed %v", tc.name, r.failed())
+		}
+	}
+
+}
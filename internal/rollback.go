@@ -0,0 +1,342 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+	"github.com/tejabeta/kopy/internal/options"
+	"github.com/tejabeta/kopy/pkg/koperator"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/rest"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// journal lists the objects created in the destination by a run, in creation order
+type journal struct {
+	RunID              string    `json:"runId"`
+	StartedAt          time.Time `json:"startedAt"`
+	SourceContext      string    `json:"sourceContext,omitempty"`
+	DestinationContext string    `json:"destinationContext,omitempty"`
+	// Absolute paths of the files the destination is read from
+	DestinationKubeconfig string `json:"destinationKubeconfig,omitempty"`
+	DestinationServer     string `json:"destinationServer,omitempty"`
+	DestinationTokenFile  string `json:"destinationTokenFile,omitempty"`
+	DestinationCAFile     string `json:"destinationCAFile,omitempty"`
+	// Names of the cluster and the user of the kubeconfig, and the impersonated
+	// identity the destination is written as
+	DestinationCluster  string         `json:"destinationCluster,omitempty"`
+	DestinationUser     string         `json:"destinationUser,omitempty"`
+	DestinationAs       string         `json:"destinationAs,omitempty"`
+	DestinationAsGroups []string       `json:"destinationAsGroups,omitempty"`
+	Objects             []journalEntry `json:"objects"`
+}
+
+// journalEntry identifies an object created by a run
+type journalEntry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// transaction records the objects created by a run into its journal, so they
+// can be deleted when the run fails, is interrupted or later on request
+type transaction struct {
+	journal       journal
+	path          string
+	keepNamespace bool
+	interrupted   int32
+	signals       chan os.Signal
+	mu            sync.Mutex
+}
+
+// newTransaction starts the journal of a run, runs which don't write into the
+// destination have none. A journal which can't be written only fails atomic runs,
+// other runs go on without it and can't be rolled back
+func newTransaction(kopyOptions *options.KopyOptions) (*transaction, error) {
+	if kopyOptions.DryRun {
+		return nil, nil
+	}
+
+	id := time.Now().Format("20060102-150405") + "-" + rand.String(5)
+	tx, err := startJournal(id, kopyOptions)
+	if err != nil {
+		if kopyOptions.Atomic {
+			return nil, fmt.Errorf("journaling run %v failed: %v", id, err)
+		}
+		log.Warn("Run ", id, " is not journaled and can't be rolled back: ", err)
+		return nil, nil
+	}
+	log.Info("Run ", id, " is journaled in ", tx.path)
+
+	if kopyOptions.Atomic {
+		tx.trapInterrupts()
+	}
+	return tx, nil
+}
+
+// startJournal writes the empty journal of a run
+func startJournal(id string, kopyOptions *options.KopyOptions) (*transaction, error) {
+	path, err := journalPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	destination := kopyOptions.Destination
+	kubeconfig, tokenFile, caFile := *destination.KubeConfig, destination.TokenFile, *destination.CAFile
+	for _, file := range []*string{&kubeconfig, &tokenFile, &caFile} {
+		if *file == "" {
+			continue
+		}
+		if *file, err = filepath.Abs(*file); err != nil {
+			return nil, err
+		}
+	}
+
+	tx := &transaction{
+		journal: journal{
+			RunID:                 id,
+			StartedAt:             time.Now(),
+			SourceContext:         kopyOptions.Source.Name(),
+			DestinationContext:    *destination.Context,
+			DestinationKubeconfig: kubeconfig,
+			DestinationServer:     *destination.APIServer,
+			DestinationTokenFile:  tokenFile,
+			DestinationCAFile:     caFile,
+			DestinationCluster:    *destination.ClusterName,
+			DestinationUser:       *destination.AuthInfoName,
+			DestinationAs:         *destination.Impersonate,
+			DestinationAsGroups:   *destination.ImpersonateGroup,
+			Objects:               []journalEntry{},
+		},
+		path:          path,
+		keepNamespace: kopyOptions.KeepNamespace,
+	}
+	if err := tx.save(); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// trapInterrupts stops the run at the next tier on the first interrupt, and
+// exits right away on the second one
+func (tx *transaction) trapInterrupts() {
+	tx.signals = make(chan os.Signal, 2)
+	signal.Notify(tx.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-tx.signals; !ok {
+			return
+		}
+		log.Warn("Interrupted, the run will be rolled back once the requests in flight are done. Interrupt again to exit right away.")
+		atomic.StoreInt32(&tx.interrupted, 1)
+
+		if _, ok := <-tx.signals; !ok {
+			return
+		}
+		log.Errorln("Exiting without rolling back, run `kopy rollback " + tx.journal.RunID + "` to clean up.")
+		os.Exit(130)
+	}()
+}
+
+// checkInterrupted returns an error once the run got interrupted
+func (tx *transaction) checkInterrupted() error {
+	if tx != nil && atomic.LoadInt32(&tx.interrupted) == 1 {
+		return errInterrupted
+	}
+	return nil
+}
+
+// close stops trapping interrupts
+func (tx *transaction) close() {
+	if tx != nil && tx.signals != nil {
+		signal.Stop(tx.signals)
+		close(tx.signals)
+	}
+}
+
+// record adds the created objects to the journal and persists it
+func (tx *transaction) record(objects ...interface{}) error {
+	if tx == nil || len(objects) == 0 {
+		return nil
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	for _, x := range objects {
+		if koperator.KindOf(x) == "Namespace" && tx.keepNamespace {
+			continue
+		}
+		obj, err := koperator.ToUnstructured(x)
+		if err != nil {
+			return err
+		}
+		tx.journal.Objects = append(tx.journal.Objects, journalEntry{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		})
+	}
+	return tx.save()
+}
+
+func (tx *transaction) save() error {
+	content, err := json.MarshalIndent(tx.journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(tx.path, content, 0600)
+}
+
+// rollback deletes the objects created by the run in reverse order
+func (tx *transaction) rollback(config *rest.Config) error {
+	if tx == nil {
+		return nil
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	log.Warn("Rolling back run ", tx.journal.RunID, ".")
+	return rollbackJournal(config, &tx.journal, false)
+}
+
+// Rollback deletes the objects created by a previous run from its journal.
+// The destination of the run is used unless another one is given
+func Rollback(runID string, destination options.Cluster, dryRun bool) error {
+	if runID == "" || filepath.Base(runID) != runID {
+		return fmt.Errorf("invalid run id %v", runID)
+	}
+
+	path, err := journalPath(runID)
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no journal found for run %v", runID)
+		}
+		return err
+	}
+
+	var j journal
+	if err := json.Unmarshal(content, &j); err != nil {
+		return fmt.Errorf("reading the journal of run %v failed: %v", runID, err)
+	}
+
+	if *destination.Context == "" && *destination.APIServer == "" {
+		*destination.Context, *destination.APIServer = j.DestinationContext, j.DestinationServer
+		destination.TokenFile, *destination.CAFile = j.DestinationTokenFile, j.DestinationCAFile
+		*destination.ClusterName, *destination.AuthInfoName = j.DestinationCluster, j.DestinationUser
+	}
+	if *destination.KubeConfig == "" {
+		*destination.KubeConfig = j.DestinationKubeconfig
+	}
+	if *destination.Impersonate == "" && len(*destination.ImpersonateGroup) == 0 {
+		*destination.Impersonate, *destination.ImpersonateGroup = j.DestinationAs, j.DestinationAsGroups
+	}
+	kopyOptions, err := options.GetDestinationOptions(destination)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Rolling back ", len(j.Objects), " objects created by run ", runID, ".")
+	return rollbackJournal(kopyOptions.DestinationContext, &j, dryRun)
+}
+
+// rollbackJournal deletes the objects of the journal in reverse order, objects
+// which are already gone are ignored
+func rollbackJournal(config *rest.Config, j *journal, dryRun bool) error {
+	kOpts := map[string]*koperator.Options{}
+	failed, deleted := 0, "Deleted"
+	if dryRun {
+		deleted = "Would delete"
+	}
+
+	for i := len(j.Objects) - 1; i >= 0; i-- {
+		entry := j.Objects[i]
+
+		opts, ok := kOpts[entry.Namespace]
+		if !ok {
+			var err error
+			opts, err = koperator.GetOpts(config, entry.Namespace)
+			if err != nil {
+				return err
+			}
+			opts.SetDryRun(dryRun)
+			kOpts[entry.Namespace] = opts
+		}
+
+		var err error
+		if entry.Kind == "Namespace" {
+			err = opts.DeleteNS(entry.Name)
+		} else {
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion(entry.APIVersion)
+			obj.SetKind(entry.Kind)
+			obj.SetNamespace(entry.Namespace)
+			obj.SetName(entry.Name)
+			err = opts.DeleteUnstructured(obj)
+		}
+
+		switch {
+		case err == nil:
+			log.Infof("%v resource %v of type %v", deleted, entry.Name, entry.Kind)
+		case apierrors.IsNotFound(err):
+			log.Infof("Resource %v of type %v is already deleted", entry.Name, entry.Kind)
+		default:
+			failed++
+			log.Errorf("Deleting resource %v of type %v failed: %v", entry.Name, entry.Kind, err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v objects of run %v could not be deleted", failed, len(j.Objects), j.RunID)
+	}
+	return nil
+}
+
+// journalPath returns the path of the journal of a run, creating its directory
+func journalPath(runID string) (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(home, ".kopy", "runs")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, runID+".json"), nil
+}
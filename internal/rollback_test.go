@@ -0,0 +1,281 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/tejabeta/kopy/internal/options"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+// newDeleteServer serves the discovery of Namespaces, ConfigMaps and Deployments and
+// records the deletions it receives, answering them with the given status by path
+func newDeleteServer(statuses map[string]int) (*httptest.Server, func() []string) {
+	var (
+		mu      sync.Mutex
+		deletes []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			return
+		case "/apis":
+			w.Write([]byte(`{"kind":"APIGroupList","groups":[{"name":"apps","versions":[{"groupVersion":"apps/v1","version":"v1"}],` +
+				`"preferredVersion":{"groupVersion":"apps/v1","version":"v1"}}]}`))
+			return
+		case "/api/v1":
+			w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+				`{"name":"namespaces","kind":"Namespace","namespaced":false,"verbs":["create","delete"]},` +
+				`{"name":"configmaps","kind":"ConfigMap","namespaced":true,"verbs":["create","delete"]}]}`))
+			return
+		case "/apis/apps/v1":
+			w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"apps/v1","resources":[` +
+				`{"name":"deployments","kind":"Deployment","namespaced":true,"verbs":["create","delete"]}]}`))
+			return
+		}
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mu.Lock()
+		deletes = append(deletes, r.URL.Path)
+		mu.Unlock()
+		if status, ok := statuses[r.URL.Path]; ok {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`))
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, deletes...)
+	}
+}
+
+// newJournal returns the journal of a run which created a namespace, a ConfigMap and a Deployment
+func newJournal(runID string) journal {
+	return journal{
+		RunID: runID,
+		Objects: []journalEntry{
+			{APIVersion: "v1", Kind: "Namespace", Name: "unit-test-ns"},
+			{APIVersion: "v1", Kind: "ConfigMap", Namespace: "unit-test-ns", Name: "unit-test-cm"},
+			{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "unit-test-ns", Name: "unit-test-deploy"},
+		},
+	}
+}
+
+// reverseDeletes are the deletions of the journal of newJournal, in reverse creation order
+var reverseDeletes = []string{
+	"/apis/apps/v1/namespaces/unit-test-ns/deployments/unit-test-deploy",
+	"/api/v1/namespaces/unit-test-ns/configmaps/unit-test-cm",
+	"/api/v1/namespaces/unit-test-ns",
+}
+
+func TestRecord(t *testing.T) {
+
+	ns := &corev1.Namespace{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"}, ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ns"}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-cm", Namespace: "unit-test-ns"}}
+	deploy := &appv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-deploy", Namespace: "unit-test-ns"}}
+	crontab := &unstructured.Unstructured{}
+	crontab.SetAPIVersion("stable.example.com/v1")
+	crontab.SetKind("CronTab")
+	crontab.SetNamespace("unit-test-ns")
+	crontab.SetName("unit-test-crontab")
+
+	testCases := []struct {
+		keepNamespace bool
+		objects       []interface{}
+		expected      []journalEntry
+	}{
+		{false, nil, []journalEntry{}},
+		{false, []interface{}{ns, cm, deploy}, newJournal("").Objects},
+		{true, []interface{}{ns, cm, deploy}, newJournal("").Objects[1:]},
+		{false, []interface{}{crontab}, []journalEntry{{APIVersion: "stable.example.com/v1", Kind: "CronTab", Namespace: "unit-test-ns", Name: "unit-test-crontab"}}},
+	}
+	for i, tc := range testCases {
+		tx := &transaction{
+			journal:       journal{RunID: "unit-test-run", Objects: []journalEntry{}},
+			path:          filepath.Join(t.TempDir(), "unit-test-run.json"),
+			keepNamespace: tc.keepNamespace,
+		}
+		if err := tx.save(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tx.record(tc.objects...); err != nil {
+			t.Errorf("Error while recording case %v, got %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tx.journal.Objects, tc.expected) {
+			t.Errorf("Error while recording case %v, got %v expected %v", i, tx.journal.Objects, tc.expected)
+		}
+
+		content, err := ioutil.ReadFile(tx.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var saved journal
+		if err := json.Unmarshal(content, &saved); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(saved.Objects, tc.expected) {
+			t.Errorf("Error while saving case %v, got %v expected %v", i, saved.Objects, tc.expected)
+		}
+	}
+
+	var tx *transaction
+	if err := tx.record(cm); err != nil {
+		t.Errorf("Error while recording without a journal, got %v", err)
+	}
+
+}
+
+func TestRollbackJournal(t *testing.T) {
+
+	testCases := []struct {
+		statuses map[string]int
+		dryRun   bool
+		fails    bool
+	}{
+		{nil, false, false},
+		{nil, true, false},
+		{map[string]int{reverseDeletes[1]: http.StatusNotFound}, false, false},
+		{map[string]int{reverseDeletes[1]: http.StatusForbidden}, false, true},
+	}
+	for i, tc := range testCases {
+		server, deletes := newDeleteServer(tc.statuses)
+		j := newJournal("unit-test-run")
+
+		err := rollbackJournal(&rest.Config{Host: server.URL}, &j, tc.dryRun)
+		server.Close()
+		if (err != nil) != tc.fails {
+			t.Errorf("Error while rolling back case %v, got %v", i, err)
+		}
+
+		// Objects which can't be deleted don't stop the rollback
+		if got := deletes(); !reflect.DeepEqual(got, reverseDeletes) {
+			t.Errorf("Error while rolling back case %v, got deletions %v expected %v", i, got, reverseDeletes)
+		}
+	}
+
+}
+
+func TestRollback(t *testing.T) {
+
+	dir := t.TempDir()
+	homedir.DisableCache = true
+	defer func(home string) { os.Setenv("HOME", home) }(os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+
+	server, deletes := newDeleteServer(nil)
+	defer server.Close()
+
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	if err := ioutil.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	j := newJournal("unit-test-run")
+	j.DestinationKubeconfig, j.DestinationServer = kubeconfig, server.URL
+	path, err := journalPath(j.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := json.Marshal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".kopy", "runs", "unit-test-corrupt.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		runID   string
+		err     string
+		deletes []string
+	}{
+		{"", "invalid run id", nil},
+		{"../unit-test-run", "invalid run id", nil},
+		{"runs/unit-test-run", "invalid run id", nil},
+		{"unit-test-missing", "no journal found", nil},
+		{"unit-test-corrupt", "reading the journal", nil},
+		{"unit-test-run", "", reverseDeletes},
+	}
+	for _, tc := range testCases {
+		before := len(deletes())
+		err := Rollback(tc.runID, options.NewCluster(), false)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("Error while rolling back run %q, got %v", tc.runID, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("Error while rolling back run %q, got %v expected %v", tc.runID, err, tc.err)
+		}
+
+		if got := deletes()[before:]; len(got) != len(tc.deletes) || (len(got) > 0 && !reflect.DeepEqual(got, tc.deletes)) {
+			t.Errorf("Error while rolling back run %q, got deletions %v expected %v", tc.runID, got, tc.deletes)
+		}
+	}
+
+}
+
+func TestNewTransactionWithoutJournal(t *testing.T) {
+
+	// The journal directory can't be created under a file
+	home := filepath.Join(t.TempDir(), "home")
+	if err := ioutil.WriteFile(home, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	homedir.DisableCache = true
+	defer func(home string) { os.Setenv("HOME", home) }(os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	testCases := []struct {
+		atomic bool
+		fails  bool
+	}{
+		{false, false},
+		{true, true},
+	}
+	for _, tc := range testCases {
+		tx, err := newTransaction(&options.KopyOptions{Atomic: tc.atomic})
+		if (err != nil) != tc.fails || tx != nil {
+			t.Errorf("Error while starting a run with atomic %v, got %v and %v", tc.atomic, tx, err)
+		}
+	}
+
+}
@@ -0,0 +1,243 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Formats of a bundle on disk
+const (
+	// FormatYAML writes all the objects into a single multi-document YAML file
+	FormatYAML = "yaml"
+	// FormatDir writes every object into its own file in a per-kind directory tree
+	FormatDir = "dir"
+	// FormatTar writes the per-kind directory tree into a tar.gz archive
+	FormatTar = "tar"
+)
+
+// Formats lists all the supported formats
+var Formats = []string{FormatYAML, FormatDir, FormatTar}
+
+const (
+	indexAPIVersion = "kopy/v1"
+	indexKind       = "Index"
+	indexFile       = "index.yaml"
+	yamlSeparator   = "---\n"
+)
+
+// Bundles hold Secrets, so they are only readable by their owner
+const (
+	fileMode os.FileMode = 0600
+	dirMode  os.FileMode = 0700
+)
+
+// Index describes the content of a bundle
+type Index struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Source     string    `json:"source,omitempty"`
+	Namespaces []string  `json:"namespaces"`
+	Objects    []Entry   `json:"objects"`
+}
+
+// Entry describes a single object of a bundle
+type Entry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+}
+
+// Bundle is a set of objects exported from one or more namespaces
+type Bundle struct {
+	Source     string
+	Namespaces []string
+	Objects    []*unstructured.Unstructured
+}
+
+// Write writes the bundle to the given path in the given format. For the yaml
+// format the index is written next to the file, bundle.yaml gets bundle.index.yaml,
+// for the other formats it is written as index.yaml at the root of the tree
+func (b *Bundle) Write(dest string, format string) error {
+	switch format {
+	case FormatYAML:
+		return b.writeYAML(dest)
+	case FormatDir:
+		return b.writeDir(dest)
+	case FormatTar:
+		return b.writeTar(dest)
+	}
+	return fmt.Errorf("unsupported bundle format %v, must be one of %v", format, strings.Join(Formats, ", "))
+}
+
+func (b *Bundle) writeYAML(dest string) error {
+	index := b.newIndex()
+	var buf bytes.Buffer
+	for _, obj := range b.Objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(yamlSeparator)
+		buf.Write(data)
+		index.add(obj, filepath.Base(dest))
+	}
+
+	if err := writeFile(dest, buf.Bytes()); err != nil {
+		return err
+	}
+	return index.write(func(data []byte) error {
+		return writeFile(strings.TrimSuffix(dest, filepath.Ext(dest))+".index.yaml", data)
+	})
+}
+
+func (b *Bundle) writeDir(dest string) error {
+	index := b.newIndex()
+	for _, obj := range b.Objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		p := objectPath(obj)
+		if err := writeFile(filepath.Join(dest, filepath.FromSlash(p)), data); err != nil {
+			return err
+		}
+		index.add(obj, p)
+	}
+
+	return index.write(func(data []byte) error {
+		return writeFile(filepath.Join(dest, indexFile), data)
+	})
+}
+
+func (b *Bundle) writeTar(dest string) (err error) {
+	if err = os.MkdirAll(filepath.Dir(dest), dirMode); err != nil {
+		return
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return
+	}
+	if err = f.Chmod(fileMode); err != nil {
+		f.Close()
+		return
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: int64(fileMode), Size: int64(len(data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	index := b.newIndex()
+	for _, obj := range b.Objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		p := objectPath(obj)
+		if err := add(p, data); err != nil {
+			return err
+		}
+		index.add(obj, p)
+	}
+
+	if err = index.write(func(data []byte) error { return add(indexFile, data) }); err != nil {
+		return
+	}
+	if err = tw.Close(); err != nil {
+		return
+	}
+	return gw.Close()
+}
+
+func (b *Bundle) newIndex() *Index {
+	return &Index{
+		APIVersion: indexAPIVersion,
+		Kind:       indexKind,
+		CreatedAt:  time.Now().UTC(),
+		Source:     b.Source,
+		Namespaces: b.Namespaces,
+		Objects:    []Entry{},
+	}
+}
+
+func (index *Index) add(obj *unstructured.Unstructured, p string) {
+	index.Objects = append(index.Objects, Entry{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		Path:       p,
+	})
+}
+
+func (index *Index) write(fn func([]byte) error) error {
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return fn(data)
+}
+
+// objectPath returns the slash separated path of an object in the directory tree,
+// <namespace>/<kind>/<name>.yaml, cluster scoped objects go into _cluster
+func objectPath(obj *unstructured.Unstructured) string {
+	ns := obj.GetNamespace()
+	if ns == "" {
+		ns = "_cluster"
+	}
+	kind := strings.ToLower(obj.GetKind())
+	if group := obj.GroupVersionKind().Group; group != "" {
+		kind += "." + group
+	}
+	return path.Join(ns, kind, obj.GetName()+".yaml")
+}
+
+func writeFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), dirMode); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(name, data, fileMode); err != nil {
+		return err
+	}
+	// An existing file keeps its mode while being overwritten
+	return os.Chmod(name, fileMode)
+}
@@ -0,0 +1,229 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func newObject(apiVersion string, kind string, namespace string, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func newBundle() *Bundle {
+	return &Bundle{
+		Source:     "https://unit-test",
+		Namespaces: []string{"unit-test-ns"},
+		Objects: []*unstructured.Unstructured{
+			newObject("v1", "Namespace", "", "unit-test-ns"),
+			newObject("v1", "ConfigMap", "unit-test-ns", "unit-test-configmap"),
+			newObject("apps/v1", "Deployment", "unit-test-ns", "unit-test-deployment"),
+		},
+	}
+}
+
+func readIndex(t *testing.T, data []byte) Index {
+	var index Index
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		t.Fatal(err.Error())
+	}
+	if index.Kind != indexKind || len(index.Objects) != 3 || index.Namespaces[0] != "unit-test-ns" {
+		t.Errorf("Unexpected bundle index %v", index)
+	}
+	return index
+}
+
+func TestWriteYAML(t *testing.T) {
+
+	dir := t.TempDir()
+	err := newBundle().Write(filepath.Join(dir, "bundle.yaml"), FormatYAML)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "bundle.yaml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if strings.Count(string(data), yamlSeparator) != 3 || !strings.Contains(string(data), "name: unit-test-deployment") {
+		t.Errorf("Unexpected multi-document YAML %s", data)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "bundle.index.yaml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	index := readIndex(t, data)
+	if index.Objects[1].Path != "bundle.yaml" {
+		t.Errorf("Unexpected object path %v", index.Objects[1].Path)
+	}
+
+}
+
+func TestWriteDir(t *testing.T) {
+
+	dir := t.TempDir()
+	err := newBundle().Write(dir, FormatDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, p := range []string{
+		"index.yaml",
+		"_cluster/namespace/unit-test-ns.yaml",
+		"unit-test-ns/configmap/unit-test-configmap.yaml",
+		"unit-test-ns/deployment.apps/unit-test-deployment.yaml",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p))); err != nil {
+			t.Errorf("Missing bundle file %v", p)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "index.yaml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	index := readIndex(t, data)
+	if index.Objects[2].Path != "unit-test-ns/deployment.apps/unit-test-deployment.yaml" {
+		t.Errorf("Unexpected object path %v", index.Objects[2].Path)
+	}
+
+}
+
+func TestWriteTar(t *testing.T) {
+
+	dir := t.TempDir()
+	err := newBundle().Write(filepath.Join(dir, "bundle.tar.gz"), FormatTar)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	f, err := os.Open(filepath.Join(dir, "bundle.tar.gz"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+
+	if len(names) != 4 || names[3] != "index.yaml" || names[1] != "unit-test-ns/configmap/unit-test-configmap.yaml" {
+		t.Errorf("Unexpected archive content %v", names)
+	}
+
+}
+
+func TestWriteUnsupportedFormat(t *testing.T) {
+
+	err := newBundle().Write(filepath.Join(t.TempDir(), "bundle"), "zip")
+	if err == nil {
+		t.Errorf("Error while writing bundle in unsupported format")
+	}
+
+}
+
+func TestWriteSecretModes(t *testing.T) {
+
+	dir := t.TempDir()
+	b := newBundle()
+	b.Objects = append(b.Objects, newObject("v1", "Secret", "unit-test-ns", "unit-test-secret"))
+
+	if err := b.Write(filepath.Join(dir, "tree"), FormatDir); err != nil {
+		t.Fatal(err.Error())
+	}
+	for p, mode := range map[string]os.FileMode{
+		"tree/unit-test-ns/secret":                       0700,
+		"tree/unit-test-ns/secret/unit-test-secret.yaml": 0600,
+	} {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("Unexpected mode of %v, got %v expected %v", p, info.Mode().Perm(), mode)
+		}
+	}
+
+	// Overwriting a readable bundle restricts it as well
+	dest := filepath.Join(dir, "bundle.yaml")
+	if err := ioutil.WriteFile(dest, nil, 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := b.Write(dest, FormatYAML); err != nil {
+		t.Fatal(err.Error())
+	}
+	if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Unexpected mode of the YAML bundle %v", info)
+	}
+
+	dest = filepath.Join(dir, "bundle.tar.gz")
+	if err := b.Write(dest, FormatTar); err != nil {
+		t.Fatal(err.Error())
+	}
+	if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Unexpected mode of the archive %v", info)
+	}
+
+	f, err := os.Open(dest)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if hdr.Mode != 0600 {
+			t.Errorf("Unexpected mode of archive entry %v, got %o", hdr.Name, hdr.Mode)
+		}
+	}
+
+}
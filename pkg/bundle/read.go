@@ -0,0 +1,186 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Read reads a bundle from a YAML or JSON file, a directory tree of such files
+// or a tar.gz archive of it. Lists are expanded into their items, and the
+// namespaces are taken from the index when the bundle has one
+func Read(src string) (*Bundle, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{}
+	switch {
+	case info.IsDir():
+		err = b.readDir(src)
+	case isArchive(src):
+		err = b.readTar(src)
+	default:
+		err = b.readFile(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b.Namespaces) == 0 {
+		b.Namespaces = b.namespaces()
+	}
+	return b, nil
+}
+
+func (b *Bundle) readDir(src string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !isManifest(p) {
+			return err
+		}
+		return b.readFile(p)
+	})
+}
+
+func (b *Bundle) readTar(src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || !isManifest(hdr.Name) {
+			continue
+		}
+		if err := b.decode(tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *Bundle) readFile(src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return b.decode(f)
+}
+
+// decode reads all the YAML or JSON documents of a stream into the bundle
+func (b *Bundle) decode(r io.Reader) error {
+	decoder := yamlutil.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		content := map[string]interface{}{}
+		if err := decoder.Decode(&content); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: content}
+		switch {
+		case obj.GetAPIVersion() == indexAPIVersion && obj.GetKind() == indexKind:
+			if err := b.readIndex(content); err != nil {
+				return err
+			}
+		case obj.IsList():
+			list, err := obj.ToList()
+			if err != nil {
+				return err
+			}
+			for i := range list.Items {
+				b.Objects = append(b.Objects, &list.Items[i])
+			}
+		default:
+			b.Objects = append(b.Objects, obj)
+		}
+	}
+}
+
+func (b *Bundle) readIndex(content map[string]interface{}) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return err
+	}
+	b.Source, b.Namespaces = index.Source, index.Namespaces
+	return nil
+}
+
+// namespaces returns the namespaces of the objects in the bundle
+func (b *Bundle) namespaces() []string {
+	seen := map[string]bool{}
+	for _, obj := range b.Objects {
+		switch {
+		case obj.GetNamespace() != "":
+			seen[obj.GetNamespace()] = true
+		case obj.GetKind() == "Namespace" && obj.GroupVersionKind().Group == "":
+			seen[obj.GetName()] = true
+		}
+	}
+
+	var result []string
+	for ns := range seen {
+		result = append(result, ns)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func isArchive(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+func isManifest(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
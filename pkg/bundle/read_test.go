@@ -0,0 +1,91 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadWrittenBundle(t *testing.T) {
+
+	for _, tc := range []struct {
+		format string
+		name   string
+	}{
+		{FormatYAML, "bundle.yaml"},
+		{FormatDir, "bundle"},
+		{FormatTar, "bundle.tar.gz"},
+	} {
+		dest := filepath.Join(t.TempDir(), tc.name)
+		if err := newBundle().Write(dest, tc.format); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		b, err := Read(dest)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if len(b.Objects) != 3 {
+			t.Errorf("Unexpected objects read from %v bundle %v", tc.format, b.Objects)
+		}
+
+		if len(b.Namespaces) != 1 || b.Namespaces[0] != "unit-test-ns" {
+			t.Errorf("Unexpected namespaces read from %v bundle %v", tc.format, b.Namespaces)
+		}
+	}
+
+}
+
+func TestReadJSONList(t *testing.T) {
+
+	src := filepath.Join(t.TempDir(), "list.json")
+	err := ioutil.WriteFile(src, []byte(`{
+		"apiVersion": "v1",
+		"kind": "List",
+		"items": [
+			{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "unit-test-configmap", "namespace": "unit-test-ns"}},
+			{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "unit-test-secret", "namespace": "unit-test-other"}}
+		]
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	b, err := Read(src)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(b.Objects) != 2 || b.Objects[1].GetKind() != "Secret" {
+		t.Errorf("Unexpected objects read from JSON list %v", b.Objects)
+	}
+
+	if len(b.Namespaces) != 2 || b.Namespaces[0] != "unit-test-ns" || b.Namespaces[1] != "unit-test-other" {
+		t.Errorf("Unexpected namespaces read from JSON list %v", b.Namespaces)
+	}
+
+}
+
+func TestReadMissingBundle(t *testing.T) {
+
+	_, err := Read(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil {
+		t.Errorf("Error while reading non existence bundle")
+	}
+
+}
@@ -0,0 +1,168 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"fmt"
+
+	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// dependentKinds are the kinds pulled into a closure by referencing one of its objects
+var dependentKinds = map[schema.GroupKind]bool{
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}: true,
+	{Group: "networking.k8s.io", Kind: "Ingress"}:             true,
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}:   true,
+}
+
+// selectingKinds are the kinds pulled into a closure by selecting the pods of one of its workloads
+var selectingKinds = map[schema.GroupKind]bool{
+	{Kind: "Service"}: true,
+	{Group: "policy", Kind: "PodDisruptionBudget"}: true,
+}
+
+// Closure returns the roots along with all the objects they need to run: the
+// objects they reference, the role bindings of their service accounts, the
+// services and disruption budgets selecting their pods, the ingresses routing
+// to those services and their autoscalers, and so on transitively
+func Closure(objects []interface{}, roots []Reference) (map[Reference]bool, error) {
+	index := map[Reference]interface{}{}
+	for _, x := range objects {
+		index[ReferenceOf(x)] = x
+	}
+
+	// Implicit objects left out of the copy aren't part of the closure, but they
+	// still pull in the objects depending on them such as their role bindings
+	closure := map[Reference]bool{}
+	visited := map[Reference]bool{}
+	var queue []Reference
+	add := func(ref Reference) {
+		_, ok := index[ref]
+		if visited[ref] || (!ok && !implicitObjects[ref]) {
+			return
+		}
+		visited[ref] = true
+		if ok {
+			closure[ref] = true
+		}
+		queue = append(queue, ref)
+	}
+
+	for _, root := range roots {
+		if _, ok := index[root]; !ok {
+			return nil, fmt.Errorf("no %v found", root)
+		}
+		add(root)
+	}
+
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+
+		var podLabels map[string]string
+		if x, ok := index[ref]; ok {
+			for _, r := range References(x) {
+				add(r)
+			}
+			podLabels = PodTemplateLabelsOf(x)
+		}
+		for _, y := range objects {
+			kind := ReferenceOf(y).GroupKind()
+			switch {
+			case dependentKinds[kind]:
+				for _, r := range References(y) {
+					if r == ref {
+						add(ReferenceOf(y))
+					}
+				}
+			case selectingKinds[kind] && podLabels != nil:
+				if selector := podSelectorOf(y); selector != nil && !selector.Empty() && selector.Matches(labels.Set(podLabels)) {
+					add(ReferenceOf(y))
+				}
+			}
+		}
+	}
+	return closure, nil
+}
+
+// PodTemplateLabelsOf returns the labels of the pods of a workload, nil for any other resource
+func PodTemplateLabelsOf(x interface{}) map[string]string {
+	switch v := x.(type) {
+	case *appv1.Deployment:
+		return v.Spec.Template.Labels
+	case *appv1.StatefulSet:
+		return v.Spec.Template.Labels
+	case *batchv1.Job:
+		return v.Spec.Template.Labels
+	case *unstructured.Unstructured:
+		path, ok := podSpecPaths[v.GetKind()]
+		if !ok {
+			return nil
+		}
+		// The labels sit next to the pod spec in the metadata of the template
+		path = append(append([]string{}, path[:len(path)-1]...), "metadata", "labels")
+		if v.GetKind() == "Pod" {
+			path = []string{"metadata", "labels"}
+		}
+		result, _, _ := unstructured.NestedStringMap(v.Object, path...)
+		if result == nil {
+			result = map[string]string{}
+		}
+		return result
+	}
+	return nil
+}
+
+// podSelectorOf returns the selector of the pods of a service or a disruption budget
+func podSelectorOf(x interface{}) labels.Selector {
+	switch v := x.(type) {
+	case *corev1.Service:
+		if len(v.Spec.Selector) == 0 {
+			return nil
+		}
+		return labels.SelectorFromSet(v.Spec.Selector)
+	case *unstructured.Unstructured:
+		switch v.GetKind() {
+		case "Service":
+			selector, _, _ := unstructured.NestedStringMap(v.Object, "spec", "selector")
+			if len(selector) == 0 {
+				return nil
+			}
+			return labels.SelectorFromSet(selector)
+		case "PodDisruptionBudget":
+			content, found, _ := unstructured.NestedMap(v.Object, "spec", "selector")
+			if !found {
+				return nil
+			}
+			var ls metav1.LabelSelector
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, &ls); err != nil {
+				return nil
+			}
+			selector, err := metav1.LabelSelectorAsSelector(&ls)
+			if err != nil {
+				return nil
+			}
+			return selector
+		}
+	}
+	return nil
+}
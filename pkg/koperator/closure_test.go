@@ -0,0 +1,198 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestClosure(t *testing.T) {
+
+	checkout := newDeployment("unit-test-checkout")
+	checkout.Spec.Template.Labels = map[string]string{"app": "checkout"}
+	cart := newDeployment("unit-test-cart")
+	cart.Spec.Template.Spec = corev1.PodSpec{}
+	cart.Spec.Template.Labels = map[string]string{"app": "cart"}
+
+	objects := []interface{}{
+		checkout,
+		cart,
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-configmap"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-other"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-secret"}},
+		newServiceAccount("unit-test-sa"),
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "unit-test-rb", Namespace: "unit-test-ns"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "unit-test-role"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "unit-test-sa"}},
+		},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-role"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-checkout"}, Spec: corev1.ServiceSpec{Selector: map[string]string{"app": "checkout"}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-cart"}, Spec: corev1.ServiceSpec{Selector: map[string]string{"app": "cart"}}},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress"},
+			Spec:       networkingv1.IngressSpec{DefaultBackend: &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "unit-test-checkout"}}},
+		},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "policy/v1beta1",
+			"kind":       "PodDisruptionBudget",
+			"metadata":   map[string]interface{}{"name": "unit-test-pdb"},
+			"spec":       map[string]interface{}{"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "checkout"}}},
+		}},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "autoscaling/v1",
+			"kind":       "HorizontalPodAutoscaler",
+			"metadata":   map[string]interface{}{"name": "unit-test-hpa"},
+			"spec":       map[string]interface{}{"scaleTargetRef": map[string]interface{}{"kind": "Deployment", "name": "unit-test-checkout"}},
+		}},
+	}
+
+	closure, err := Closure(objects, []Reference{{Group: "apps", Kind: "Deployment", Name: "unit-test-checkout"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []Reference{
+		{Group: "apps", Kind: "Deployment", Name: "unit-test-checkout"},
+		{Kind: "ConfigMap", Name: "unit-test-configmap"},
+		{Kind: "Secret", Name: "unit-test-secret"},
+		{Kind: "ServiceAccount", Name: "unit-test-sa"},
+		{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding", Name: "unit-test-rb"},
+		{Group: "rbac.authorization.k8s.io", Kind: "Role", Name: "unit-test-role"},
+		{Kind: "Service", Name: "unit-test-checkout"},
+		{Group: "networking.k8s.io", Kind: "Ingress", Name: "unit-test-ingress"},
+		{Group: "policy", Kind: "PodDisruptionBudget", Name: "unit-test-pdb"},
+		{Group: "autoscaling", Kind: "HorizontalPodAutoscaler", Name: "unit-test-hpa"},
+	}
+	if len(closure) != len(expected) {
+		t.Errorf("Error while computing the closure, got %v", closure)
+	}
+	for _, ref := range expected {
+		if !closure[ref] {
+			t.Errorf("Error while computing the closure, %v is missing", ref)
+		}
+	}
+
+	_, err = Closure(objects, []Reference{{Group: "apps", Kind: "Deployment", Name: "unit-test-missing"}})
+	if err == nil {
+		t.Errorf("Error while computing the closure of a missing object")
+	}
+
+}
+
+func TestClosureImplicitServiceAccount(t *testing.T) {
+
+	// The default service account is skipped by default, its role bindings are not
+	deployment := newDeployment("unit-test-deployment")
+	deployment.Spec.Template.Spec = corev1.PodSpec{}
+	objects := []interface{}{
+		deployment,
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "unit-test-rb", Namespace: "unit-test-ns"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "unit-test-role"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "default"}},
+		},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-role"}},
+	}
+
+	closure, err := Closure(objects, []Reference{{Group: "apps", Kind: "Deployment", Name: "unit-test-deployment"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []Reference{
+		{Group: "apps", Kind: "Deployment", Name: "unit-test-deployment"},
+		{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding", Name: "unit-test-rb"},
+		{Group: "rbac.authorization.k8s.io", Kind: "Role", Name: "unit-test-role"},
+	}
+	if len(closure) != len(expected) {
+		t.Errorf("Error while computing the closure, got %v", closure)
+	}
+	for _, ref := range expected {
+		if !closure[ref] {
+			t.Errorf("Error while computing the closure, %v is missing", ref)
+		}
+	}
+
+}
+
+func TestParseReference(t *testing.T) {
+
+	resources := []APIResource{
+		{GroupVersionResource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, Kind: "Deployment", ShortNames: []string{"deploy"}},
+	}
+
+	ref, err := ParseReference("deploy/checkout", resources)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ref != (Reference{Group: "apps", Kind: "Deployment", Name: "checkout"}) {
+		t.Errorf("Error while parsing a reference, got %v", ref)
+	}
+
+	for _, invalid := range []string{"checkout", "deploy/", "crontab/checkout"} {
+		if _, err := ParseReference(invalid, resources); err == nil {
+			t.Errorf("Error while parsing invalid reference %v", invalid)
+		}
+	}
+
+}
+
+func TestReferenceGroups(t *testing.T) {
+
+	// A Knative Service owning a core Service of the same name
+	knative := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "unit-test-app"},
+	}}
+	core := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-app"}}
+
+	resources := []APIResource{
+		{GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "services"}, Kind: "Service", ShortNames: []string{"svc"}},
+		{GroupVersionResource: schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1", Resource: "services"}, Kind: "Service", ShortNames: []string{"ksvc"}},
+	}
+	ref, err := ParseReference("service.serving.knative.dev/unit-test-app", resources)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ref != ReferenceOf(knative) {
+		t.Errorf("Error while parsing a reference qualified by a group, got %v", ref)
+	}
+
+	closure, err := Closure([]interface{}{knative, core}, []Reference{ref})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(closure) != 1 || !closure[ReferenceOf(knative)] {
+		t.Errorf("Error while computing the closure of a custom resource, got %v", closure)
+	}
+
+	skipper, err := NewSkipper(false, []Reference{{Kind: "Service", Name: "unit-test-*"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if skipper.Skip(core) != SkipUserProvided || skipper.Skip(knative) != "" {
+		t.Errorf("Error while skipping core services, skipped the custom resource of the same kind")
+	}
+
+}
@@ -0,0 +1,188 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"fmt"
+	"strings"
+
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// compatibleVersions are the versions of the kinds which moved across versions or
+// groups without changing their schema, newest first. Objects are converted from
+// one to the other by changing their apiVersion
+var compatibleVersions = compatible(
+	[]schema.GroupVersionKind{
+		{Group: "batch", Version: "v1", Kind: "CronJob"},
+		{Group: "batch", Version: "v1beta1", Kind: "CronJob"},
+		{Group: "batch", Version: "v2alpha1", Kind: "CronJob"},
+	},
+	[]schema.GroupVersionKind{
+		{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"},
+		{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"},
+	},
+	[]schema.GroupVersionKind{
+		{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"},
+		{Group: "autoscaling", Version: "v2beta2", Kind: "HorizontalPodAutoscaler"},
+	},
+	[]schema.GroupVersionKind{
+		{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+		{Group: "extensions", Version: "v1beta1", Kind: "NetworkPolicy"},
+	},
+	[]schema.GroupVersionKind{
+		{Group: "coordination.k8s.io", Version: "v1", Kind: "Lease"},
+		{Group: "coordination.k8s.io", Version: "v1beta1", Kind: "Lease"},
+	},
+)
+
+func compatible(sets ...[]schema.GroupVersionKind) map[schema.GroupVersionKind][]schema.GroupVersionKind {
+	result := map[schema.GroupVersionKind][]schema.GroupVersionKind{}
+	for _, set := range sets {
+		for _, gvk := range set {
+			result[gvk] = set
+		}
+	}
+	return result
+}
+
+// NotServedError is returned for the objects of a kind which has no equivalent in the destination
+type NotServedError struct {
+	schema.GroupVersionKind
+}
+
+func (e *NotServedError) Error() string {
+	return fmt.Sprintf("%v %v has no equivalent served by the destination", e.Kind, e.GroupVersion())
+}
+
+// IsNotServed tells if the error is a NotServedError
+func IsNotServed(err error) bool {
+	_, ok := err.(*NotServedError)
+	return ok
+}
+
+// Converter converts the objects read from a source cluster into a version served by
+// the destination cluster, when it doesn't serve the version they were read with
+type Converter struct {
+	// versions served for each kind, the preferred one first
+	versions map[schema.GroupKind][]string
+}
+
+// NewConverter discovers the kinds and versions served by the destination
+func NewConverter(destination *rest.Config) (*Converter, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(destination)
+	if err != nil {
+		return nil, err
+	}
+	return newConverter(dc)
+}
+
+func newConverter(dc discovery.DiscoveryInterface) (*Converter, error) {
+	_, lists, err := dc.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+
+	// The lists come in the order of preference of the groups and their versions
+	c := &Converter{versions: map[schema.GroupKind][]string{}}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+			gk := gv.WithKind(r.Kind).GroupKind()
+			c.versions[gk] = append(c.versions[gk], gv.Version)
+		}
+	}
+	return c, nil
+}
+
+// Version returns the version of the kind served by the destination, it is the given
+// one when served. A NotServedError is returned when the destination serves no
+// equivalent of the kind
+func (c *Converter) Version(gvk schema.GroupVersionKind) (schema.GroupVersionKind, error) {
+	if c.serves(gvk) {
+		return gvk, nil
+	}
+
+	for _, candidate := range compatibleVersions[gvk] {
+		if c.serves(candidate) {
+			return candidate, nil
+		}
+	}
+
+	if versions := c.versions[gvk.GroupKind()]; len(versions) > 0 {
+		return gvk, fmt.Errorf("%v %v can't be converted to %v served by the destination", gvk.Kind, gvk.GroupVersion(), strings.Join(versions, ", "))
+	}
+	return gvk, &NotServedError{gvk}
+}
+
+// Convert converts the object into a version served by the destination
+func (c *Converter) Convert(u *unstructured.Unstructured) error {
+	gvk, err := c.Version(u.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	u.SetGroupVersionKind(gvk)
+	return nil
+}
+
+// Unconvertible returns the resources of the source which have no equivalent served by the destination
+func (c *Converter) Unconvertible(resources []APIResource) (result []APIResource) {
+	for _, r := range resources {
+		if _, err := c.Version(r.GroupVersion().WithKind(r.Kind)); IsNotServed(err) {
+			result = append(result, r)
+		}
+	}
+	return
+}
+
+func (c *Converter) serves(gvk schema.GroupVersionKind) bool {
+	for _, version := range c.versions[gvk.GroupKind()] {
+		if version == gvk.Version {
+			return true
+		}
+	}
+	return false
+}
+
+// ServedVersion converts a resource into a version served by the cluster. Ingresses are
+// converted to the negotiated version and unstructured objects by the converter set,
+// other resources are returned as is
+func (kOpts *Options) ServedVersion(x interface{}) (interface{}, error) {
+	switch v := x.(type) {
+	case *networkingv1.Ingress, *netv1beta1.Ingress, *extv1beta1.Ingress:
+		version, err := kOpts.IngressVersion()
+		if err != nil {
+			return nil, err
+		}
+		return ConvertIngress(x, version)
+	case *unstructured.Unstructured:
+		if kOpts.converter != nil {
+			return v, kOpts.converter.Convert(v)
+		}
+	}
+	return x, nil
+}
@@ -0,0 +1,126 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestConverter(t *testing.T) *Converter {
+	cs := testclient.NewSimpleClientset()
+	cs.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{
+			{Name: "jobs", Kind: "Job", Namespaced: true},
+			{Name: "cronjobs", Kind: "CronJob", Namespaced: true},
+			{Name: "cronjobs/status", Kind: "CronJob", Namespaced: true},
+		}},
+		{GroupVersion: "autoscaling/v2beta2", APIResources: []metav1.APIResource{{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true}}},
+		{GroupVersion: "autoscaling/v1", APIResources: []metav1.APIResource{{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true}}},
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "networkpolicies", Kind: "NetworkPolicy", Namespaced: true}}},
+		{GroupVersion: "stable.example.com/v2", APIResources: []metav1.APIResource{{Name: "crontabs", Kind: "CronTab", Namespaced: true}}},
+	}
+
+	converter, err := newConverter(cs.Discovery())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return converter
+}
+
+func TestConverter(t *testing.T) {
+
+	converter := newTestConverter(t)
+
+	tests := []struct {
+		input    schema.GroupVersionKind
+		expected schema.GroupVersionKind
+	}{
+		{schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}},
+		{schema.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: "CronJob"}, schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}},
+		{schema.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "HorizontalPodAutoscaler"}, schema.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "HorizontalPodAutoscaler"}},
+		{schema.GroupVersionKind{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"}, schema.GroupVersionKind{Group: "autoscaling", Version: "v2beta2", Kind: "HorizontalPodAutoscaler"}},
+		{schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "NetworkPolicy"}, schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"}},
+	}
+
+	for _, test := range tests {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(test.input)
+		u.SetName("unit-test-object")
+
+		if err := converter.Convert(u); err != nil {
+			t.Errorf("Error while converting %v: %v", test.input, err)
+			continue
+		}
+		if u.GroupVersionKind() != test.expected || u.GetName() != "unit-test-object" {
+			t.Errorf("Error while converting %v, got %v instead of %v", test.input, u.GroupVersionKind(), test.expected)
+		}
+	}
+
+	pdb := &unstructured.Unstructured{}
+	pdb.SetGroupVersionKind(schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"})
+	if err := converter.Convert(pdb); !IsNotServed(err) {
+		t.Errorf("Error while converting a kind without equivalent, got %v", err)
+	}
+
+	cronTab := newCronTab("unit-test-crontab")
+	if err := converter.Convert(cronTab); err == nil || IsNotServed(err) {
+		t.Errorf("Error while converting a kind served with an unknown version, got %v", err)
+	}
+
+}
+
+func TestConverterUnconvertible(t *testing.T) {
+
+	converter := newTestConverter(t)
+	resources := []APIResource{
+		{GroupVersionResource: schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}, Kind: "CronJob"},
+		{GroupVersionResource: schema.GroupVersionResource{Group: "policy", Version: "v1beta1", Resource: "poddisruptionbudgets"}, Kind: "PodDisruptionBudget"},
+		{GroupVersionResource: schema.GroupVersionResource{Group: "stable.example.com", Version: "v1", Resource: "crontabs"}, Kind: "CronTab"},
+	}
+
+	output := converter.Unconvertible(resources)
+	if len(output) != 1 || output[0].Kind != "PodDisruptionBudget" {
+		t.Errorf("Error while listing kinds without equivalent, got %v", output)
+	}
+
+}
+
+func TestServedVersion(t *testing.T) {
+
+	options := Options{converter: newTestConverter(t)}
+
+	cronJob := &unstructured.Unstructured{}
+	cronJob.SetGroupVersionKind(schema.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: "CronJob"})
+
+	output, err := options.ServedVersion(cronJob)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if output.(*unstructured.Unstructured).GetAPIVersion() != "batch/v1" {
+		t.Errorf("Error while converting to the served version, got %v", output)
+	}
+
+	deployment := newDeployment("unit-test-deployment")
+	if output, err := options.ServedVersion(deployment); err != nil || output != deployment {
+		t.Errorf("Error while keeping typed resource as is, got %v", output)
+	}
+
+}
@@ -0,0 +1,155 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// KindFilter selects API resources by their plural or singular name, kind or
+// short name, optionally qualified by their group such as deployments.apps
+type KindFilter struct {
+	include []string
+	exclude []string
+}
+
+// NewKindFilter returns a filter allowing the included resources, or all of
+// them when none is given, minus the excluded ones
+func NewKindFilter(include []string, exclude []string) *KindFilter {
+	return &KindFilter{include: normalize(include), exclude: normalize(exclude)}
+}
+
+// Allows tells if the resource passes the filter
+func (f *KindFilter) Allows(r APIResource) bool {
+	if len(f.include) > 0 && !matchesAny(f.include, r) {
+		return false
+	}
+	return !matchesAny(f.exclude, r)
+}
+
+// Includes tells if the resource is included explicitly, rather than by
+// default when no resource is included
+func (f *KindFilter) Includes(r APIResource) bool {
+	return matchesAny(f.include, r) && !matchesAny(f.exclude, r)
+}
+
+// Validate returns an error when a name of the filter matches none of the resources
+func (f *KindFilter) Validate(resources []APIResource) error {
+	for _, name := range append(append([]string{}, f.include...), f.exclude...) {
+		found := false
+		for _, r := range resources {
+			if matches(name, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("no resource type %v is served by the cluster", name)
+		}
+	}
+	return nil
+}
+
+// ParseReference parses a type/name reference such as deploy/checkout, the type
+// being resolved the same way as by a KindFilter
+func ParseReference(s string, resources []APIResource) (Reference, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Reference{}, fmt.Errorf("invalid reference %v, must be <type>/<name>", s)
+	}
+
+	name := strings.ToLower(parts[0])
+	for _, r := range resources {
+		if matches(name, r) {
+			return newReference(r.GroupVersion().WithKind(r.Kind).GroupKind(), parts[1]), nil
+		}
+	}
+	return Reference{}, fmt.Errorf("no resource type %v is served by the cluster", parts[0])
+}
+
+func normalize(names []string) (result []string) {
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			result = append(result, name)
+		}
+	}
+	return
+}
+
+func matchesAny(names []string, r APIResource) bool {
+	for _, name := range names {
+		if matches(name, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches tells if the name refers to the resource, a name qualified by a group
+// only matches the resources of that group
+func matches(name string, r APIResource) bool {
+	if name == r.Resource || name == r.SingularName || name == strings.ToLower(r.Kind) {
+		return true
+	}
+	for _, short := range r.ShortNames {
+		if name == short {
+			return true
+		}
+	}
+
+	if i := strings.Index(name, "."); i > 0 && name[i+1:] == r.Group {
+		return matches(name[:i], r)
+	}
+	return false
+}
+
+// NameFilter selects objects by name with glob patterns
+type NameFilter struct {
+	include []string
+	exclude []string
+}
+
+// NewNameFilter returns a filter allowing the names matching one of the included
+// patterns, or all of them when none is given, minus the ones matching an excluded pattern
+func NewNameFilter(include []string, exclude []string) (*NameFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %v", pattern)
+		}
+	}
+	return &NameFilter{include: include, exclude: exclude}, nil
+}
+
+// Allows tells if the name passes the filter
+func (f *NameFilter) Allows(name string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.include) > 0 && !matchesPattern(f.include, name) {
+		return false
+	}
+	return !matchesPattern(f.exclude, name)
+}
+
+func matchesPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var filterResources = []APIResource{
+	{GroupVersionResource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, Kind: "Deployment", SingularName: "deployment", ShortNames: []string{"deploy"}},
+	{GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, Kind: "ConfigMap", SingularName: "configmap", ShortNames: []string{"cm"}},
+	{GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, Kind: "Secret", SingularName: "secret"},
+	{GroupVersionResource: schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "ingresses"}, Kind: "Ingress", ShortNames: []string{"ing"}},
+	{GroupVersionResource: schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}, Kind: "Ingress", ShortNames: []string{"ing"}},
+}
+
+func allowed(f *KindFilter) (result []string) {
+	for _, r := range filterResources {
+		if f.Allows(r) {
+			result = append(result, r.GroupResource().String())
+		}
+	}
+	return
+}
+
+func TestKindFilter(t *testing.T) {
+
+	tests := []struct {
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{nil, nil, []string{"deployments.apps", "configmaps", "secrets", "ingresses.extensions", "ingresses.networking.k8s.io"}},
+		{[]string{"deploy", "CM"}, nil, []string{"deployments.apps", "configmaps"}},
+		{nil, []string{"secret"}, []string{"deployments.apps", "configmaps", "ingresses.extensions", "ingresses.networking.k8s.io"}},
+		{[]string{"ingresses.networking.k8s.io", "Deployment.apps"}, nil, []string{"deployments.apps", "ingresses.networking.k8s.io"}},
+		{[]string{"ing"}, []string{"ingresses.extensions"}, []string{"ingresses.networking.k8s.io"}},
+		{[]string{"deployments.extensions"}, nil, nil},
+	}
+
+	for _, test := range tests {
+		result := allowed(NewKindFilter(test.include, test.exclude))
+		if len(result) != len(test.expected) {
+			t.Errorf("Error while filtering %v minus %v, got %v instead of %v", test.include, test.exclude, result, test.expected)
+			continue
+		}
+		for i := range result {
+			if result[i] != test.expected[i] {
+				t.Errorf("Error while filtering %v minus %v, got %v instead of %v", test.include, test.exclude, result, test.expected)
+				break
+			}
+		}
+	}
+
+}
+
+func TestKindFilterValidate(t *testing.T) {
+
+	if err := NewKindFilter([]string{"deploy", "ing"}, []string{"secrets"}).Validate(filterResources); err != nil {
+		t.Errorf("Error while validating served resource types: %v", err)
+	}
+
+	if err := NewKindFilter(nil, []string{"crontabs"}).Validate(filterResources); err == nil {
+		t.Errorf("Error while validating unknown resource types")
+	}
+
+}
+
+func TestKindFilterIncludes(t *testing.T) {
+
+	tests := []struct {
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{nil, nil, nil},
+		{[]string{"deploy", "CM"}, nil, []string{"deployments.apps", "configmaps"}},
+		{[]string{"ing"}, []string{"ingresses.extensions"}, []string{"ingresses.networking.k8s.io"}},
+	}
+
+	for _, test := range tests {
+		filter := NewKindFilter(test.include, test.exclude)
+		var result []string
+		for _, r := range filterResources {
+			if filter.Includes(r) {
+				result = append(result, r.GroupResource().String())
+			}
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Error while including %v minus %v, got %v instead of %v", test.include, test.exclude, result, test.expected)
+		}
+	}
+
+}
+
+func TestNameFilter(t *testing.T) {
+
+	filter, err := NewNameFilter([]string{"checkout-*", "cart"}, []string{"*-test"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := map[string]bool{
+		"checkout-api":  true,
+		"checkout-test": false,
+		"cart":          true,
+		"cart-db":       false,
+	}
+	for name, expected := range tests {
+		if filter.Allows(name) != expected {
+			t.Errorf("Error while filtering name %v", name)
+		}
+	}
+
+	var empty *NameFilter
+	if !empty.Allows("anything") {
+		t.Errorf("Error while filtering names without a filter")
+	}
+
+	if _, err := NewNameFilter([]string{"[checkout"}, nil); err == nil {
+		t.Errorf("Error while validating an invalid name pattern")
+	}
+
+}
@@ -0,0 +1,256 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"context"
+	"fmt"
+
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ingressClassAnnotation selects the ingress controller before ingressClassName
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+// IngressVersions are the versions Ingresses can be served with, the preferred one first.
+// Ingresses are handled as networking.k8s.io/v1 and converted from and to the version
+// served by each cluster
+var IngressVersions = []schema.GroupVersion{
+	networkingv1.SchemeGroupVersion,
+	netv1beta1.SchemeGroupVersion,
+	extv1beta1.SchemeGroupVersion,
+}
+
+// IngressVersion returns the preferred version of Ingresses served by the cluster
+func (kOpts *Options) IngressVersion() (schema.GroupVersion, error) {
+	if !kOpts.ingressVersion.Empty() {
+		return kOpts.ingressVersion, nil
+	}
+
+	groups, err := kOpts.clientset.Discovery().ServerGroups()
+	if err != nil {
+		return schema.GroupVersion{}, err
+	}
+
+	served := map[string]bool{}
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			served[version.GroupVersion] = true
+		}
+	}
+
+	// networking.k8s.io/v1 is served before Ingresses are part of it
+	for _, version := range IngressVersions {
+		if !served[version.String()] {
+			continue
+		}
+		resources, err := kOpts.clientset.Discovery().ServerResourcesForGroupVersion(version.String())
+		if err != nil {
+			return schema.GroupVersion{}, err
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == "ingresses" {
+				return version, nil
+			}
+		}
+	}
+	return schema.GroupVersion{}, fmt.Errorf("no version of Ingresses is served by the cluster")
+}
+
+// ResolveIngressVersion discovers the version of Ingresses served by the cluster once,
+// instead of on every Ingress call
+func (kOpts *Options) ResolveIngressVersion() (err error) {
+	kOpts.ingressVersion, err = kOpts.IngressVersion()
+	return
+}
+
+// createServedIngress creates an Ingress of the version served by the cluster
+func (kOpts *Options) createServedIngress(x interface{}) (result interface{}, err error) {
+	switch v := x.(type) {
+	case *networkingv1.Ingress:
+		return kOpts.clientset.NetworkingV1().Ingresses(kOpts.namespace).Create(context.TODO(), v, kOpts.createOptions())
+	case *netv1beta1.Ingress:
+		return kOpts.clientset.NetworkingV1beta1().Ingresses(kOpts.namespace).Create(context.TODO(), v, kOpts.createOptions())
+	case *extv1beta1.Ingress:
+		return kOpts.clientset.ExtensionsV1beta1().Ingresses(kOpts.namespace).Create(context.TODO(), v, kOpts.createOptions())
+	}
+	return nil, fmt.Errorf("unsupported ingress type %T", x)
+}
+
+// ConvertIngress converts an Ingress of any supported version into the given version
+func ConvertIngress(x interface{}, to schema.GroupVersion) (interface{}, error) {
+	var hub *networkingv1.Ingress
+	switch v := x.(type) {
+	case *networkingv1.Ingress:
+		hub = v
+	case *netv1beta1.Ingress:
+		hub = ingressToV1(v)
+	case *extv1beta1.Ingress:
+		beta := &netv1beta1.Ingress{}
+		if err := convertIngressGroup(v, beta); err != nil {
+			return nil, err
+		}
+		hub = ingressToV1(beta)
+	default:
+		return nil, fmt.Errorf("unsupported ingress type %T", x)
+	}
+
+	switch to {
+	case networkingv1.SchemeGroupVersion:
+		return hub, nil
+	case netv1beta1.SchemeGroupVersion:
+		return ingressToV1beta1(hub), nil
+	case extv1beta1.SchemeGroupVersion:
+		ext := &extv1beta1.Ingress{}
+		if err := convertIngressGroup(ingressToV1beta1(hub), ext); err != nil {
+			return nil, err
+		}
+
+		// Clusters serving Ingresses from extensions only predate ingressClassName
+		if ext.Spec.IngressClassName != nil {
+			if _, ok := ext.Annotations[ingressClassAnnotation]; !ok {
+				if ext.Annotations == nil {
+					ext.Annotations = map[string]string{}
+				}
+				ext.Annotations[ingressClassAnnotation] = *ext.Spec.IngressClassName
+			}
+			ext.Spec.IngressClassName = nil
+		}
+		return ext, nil
+	}
+	return nil, fmt.Errorf("unsupported ingress version %v", to)
+}
+
+// convertIngressGroup converts between the v1beta1 Ingresses of the extensions and
+// networking.k8s.io groups, which have the same fields
+func convertIngressGroup(in runtime.Object, out runtime.Object) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(in)
+	if err != nil {
+		return err
+	}
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(content, out)
+}
+
+// ingressToV1 converts a v1beta1 Ingress, moving the backends to the service
+// name and port shape of v1 which also requires a path type
+func ingressToV1(in *netv1beta1.Ingress) *networkingv1.Ingress {
+	out := &networkingv1.Ingress{
+		ObjectMeta: in.ObjectMeta,
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: in.Spec.IngressClassName,
+			DefaultBackend:   backendToV1(in.Spec.Backend),
+		},
+		Status: networkingv1.IngressStatus{LoadBalancer: in.Status.LoadBalancer},
+	}
+
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, networkingv1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+	}
+
+	for _, rule := range in.Spec.Rules {
+		r := networkingv1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &networkingv1.HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				pathType := networkingv1.PathTypeImplementationSpecific
+				if p.PathType != nil {
+					pathType = networkingv1.PathType(*p.PathType)
+				}
+				r.HTTP.Paths = append(r.HTTP.Paths, networkingv1.HTTPIngressPath{
+					Path:     p.Path,
+					PathType: &pathType,
+					Backend:  *backendToV1(&p.Backend),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, r)
+	}
+	return out
+}
+
+func backendToV1(in *netv1beta1.IngressBackend) *networkingv1.IngressBackend {
+	if in == nil {
+		return nil
+	}
+
+	out := &networkingv1.IngressBackend{Resource: in.Resource}
+	if in.ServiceName != "" {
+		out.Service = &networkingv1.IngressServiceBackend{Name: in.ServiceName}
+		if in.ServicePort.Type == intstr.String {
+			out.Service.Port.Name = in.ServicePort.StrVal
+		} else {
+			out.Service.Port.Number = in.ServicePort.IntVal
+		}
+	}
+	return out
+}
+
+// ingressToV1beta1 converts a v1 Ingress back to the serviceName and servicePort
+// shape of the backends of v1beta1
+func ingressToV1beta1(in *networkingv1.Ingress) *netv1beta1.Ingress {
+	out := &netv1beta1.Ingress{
+		ObjectMeta: in.ObjectMeta,
+		Spec: netv1beta1.IngressSpec{
+			IngressClassName: in.Spec.IngressClassName,
+			Backend:          backendToV1beta1(in.Spec.DefaultBackend),
+		},
+		Status: netv1beta1.IngressStatus{LoadBalancer: in.Status.LoadBalancer},
+	}
+
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, netv1beta1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+	}
+
+	for _, rule := range in.Spec.Rules {
+		r := netv1beta1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &netv1beta1.HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				path := netv1beta1.HTTPIngressPath{Path: p.Path, Backend: *backendToV1beta1(&p.Backend)}
+				if p.PathType != nil {
+					pathType := netv1beta1.PathType(*p.PathType)
+					path.PathType = &pathType
+				}
+				r.HTTP.Paths = append(r.HTTP.Paths, path)
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, r)
+	}
+	return out
+}
+
+func backendToV1beta1(in *networkingv1.IngressBackend) *netv1beta1.IngressBackend {
+	if in == nil {
+		return nil
+	}
+
+	out := &netv1beta1.IngressBackend{Resource: in.Resource}
+	if in.Service != nil {
+		out.ServiceName = in.Service.Name
+		if in.Service.Port.Name != "" {
+			out.ServicePort = intstr.FromString(in.Service.Port.Name)
+		} else {
+			out.ServicePort = intstr.FromInt(int(in.Service.Port.Number))
+		}
+	}
+	return out
+}
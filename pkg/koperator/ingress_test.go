@@ -0,0 +1,126 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"testing"
+
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIngressVersion(t *testing.T) {
+
+	ingresses := []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}}
+	policies := []metav1.APIResource{{Name: "networkpolicies", Kind: "NetworkPolicy", Namespaced: true}}
+
+	tests := []struct {
+		resources []*metav1.APIResourceList
+		expected  string
+	}{
+		{[]*metav1.APIResourceList{
+			{GroupVersion: "extensions/v1beta1", APIResources: ingresses},
+			{GroupVersion: "networking.k8s.io/v1beta1", APIResources: ingresses},
+			{GroupVersion: "networking.k8s.io/v1", APIResources: ingresses},
+		}, "networking.k8s.io/v1"},
+		{[]*metav1.APIResourceList{
+			{GroupVersion: "extensions/v1beta1", APIResources: ingresses},
+			{GroupVersion: "networking.k8s.io/v1beta1", APIResources: ingresses},
+			{GroupVersion: "networking.k8s.io/v1", APIResources: policies},
+		}, "networking.k8s.io/v1beta1"},
+		{[]*metav1.APIResourceList{
+			{GroupVersion: "extensions/v1beta1", APIResources: ingresses},
+		}, "extensions/v1beta1"},
+	}
+
+	for _, test := range tests {
+		cs := testclient.NewSimpleClientset()
+		cs.Resources = test.resources
+		options := Options{clientset: cs}
+
+		version, err := options.IngressVersion()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if version.String() != test.expected {
+			t.Errorf("Error while discovering ingress version, got %v instead of %v", version, test.expected)
+		}
+	}
+
+	options := Options{clientset: testclient.NewSimpleClientset()}
+	if _, err := options.IngressVersion(); err == nil {
+		t.Errorf("Error while discovering ingress version of a cluster serving none")
+	}
+
+}
+
+func TestConvertIngress(t *testing.T) {
+
+	exact := extv1beta1.PathTypeExact
+	className := "nginx"
+	input := &extv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress"},
+		Spec: extv1beta1.IngressSpec{
+			IngressClassName: &className,
+			Backend:          &extv1beta1.IngressBackend{ServiceName: "unit-test-default", ServicePort: intstr.FromString("http")},
+			TLS:              []extv1beta1.IngressTLS{{Hosts: []string{"example.com"}, SecretName: "unit-test-tls"}},
+			Rules: []extv1beta1.IngressRule{{
+				Host: "example.com",
+				IngressRuleValue: extv1beta1.IngressRuleValue{HTTP: &extv1beta1.HTTPIngressRuleValue{Paths: []extv1beta1.HTTPIngressPath{
+					{Path: "/api", PathType: &exact, Backend: extv1beta1.IngressBackend{ServiceName: "unit-test-api", ServicePort: intstr.FromInt(8080)}},
+					{Path: "/", Backend: extv1beta1.IngressBackend{ServiceName: "unit-test-web", ServicePort: intstr.FromInt(80)}},
+				}}},
+			}},
+		},
+	}
+
+	output, err := ConvertIngress(input, networkingv1.SchemeGroupVersion)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v1Ingress := output.(*networkingv1.Ingress)
+	if b := v1Ingress.Spec.DefaultBackend; b == nil || b.Service.Name != "unit-test-default" || b.Service.Port.Name != "http" {
+		t.Errorf("Error while converting ingress default backend, got %v", v1Ingress.Spec.DefaultBackend)
+	}
+
+	paths := v1Ingress.Spec.Rules[0].HTTP.Paths
+	if paths[0].Backend.Service.Port.Number != 8080 || *paths[0].PathType != networkingv1.PathTypeExact {
+		t.Errorf("Error while converting ingress paths, got %v", paths[0])
+	}
+
+	if *paths[1].PathType != networkingv1.PathTypeImplementationSpecific || *v1Ingress.Spec.IngressClassName != "nginx" {
+		t.Errorf("Error while defaulting ingress path type, got %v", paths[1])
+	}
+
+	output, err = ConvertIngress(v1Ingress, netv1beta1.SchemeGroupVersion)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	beta := output.(*netv1beta1.Ingress)
+	if beta.Spec.Backend.ServicePort != intstr.FromString("http") || beta.Spec.Rules[0].HTTP.Paths[1].Backend.ServicePort != intstr.FromInt(80) {
+		t.Errorf("Error while converting ingress back to v1beta1, got %v", beta.Spec)
+	}
+
+	if beta.Spec.TLS[0].SecretName != "unit-test-tls" || *beta.Spec.IngressClassName != "nginx" {
+		t.Errorf("Error while converting ingress back to v1beta1, got %v", beta.Spec)
+	}
+
+}
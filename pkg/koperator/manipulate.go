@@ -15,43 +15,249 @@ limitations under the License.
 package koperator
 
 import (
-	"fmt"
+	"reflect"
+	"strings"
 
 	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	v1beta1 "k8s.io/api/extensions/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
-// ManipulateResource helps to manipulate resources
+const (
+	nsNameLabel   = "kubernetes.io/metadata.name"
+	shortIDLength = 5
+)
+
+// pvcBindingAnnotations are set while binding a claim to a volume of the source cluster
+var pvcBindingAnnotations = []string{
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+}
+
+// jobControllerLabels are set by the job controller to select the pods of a job
+var jobControllerLabels = []string{
+	"controller-uid",
+	"batch.kubernetes.io/controller-uid",
+}
+
+// sanitizedMetadata are the fields of the metadata owned by the source cluster
+var sanitizedMetadata = []string{
+	"uid",
+	"resourceVersion",
+	"creationTimestamp",
+	"deletionTimestamp",
+	"deletionGracePeriodSeconds",
+	"managedFields",
+	"selfLink",
+	"generation",
+	"ownerReferences",
+}
+
+// sanitizedAnnotations are written by clients and controllers of the source cluster
+var sanitizedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+}
+
+// kindRules sanitize the fields of a kind allocated or generated by the source cluster
+var kindRules = map[schema.GroupKind]func(content map[string]interface{}){
+	{Group: "", Kind: "Service"}:               sanitizeService,
+	{Group: "", Kind: "ServiceAccount"}:        sanitizeServiceAccount,
+	{Group: "apps", Kind: "StatefulSet"}:       sanitizeStatefulSet,
+	{Group: "", Kind: "PersistentVolumeClaim"}: sanitizePVC,
+	{Group: "batch", Kind: "Job"}:              sanitizeJob,
+}
+
+// ManipulateResource sanitizes a typed or unstructured resource so it can be created
+// in another cluster. Typed resources are sanitized through their unstructured content
+// so that the same rules apply to both
 func ManipulateResource(x interface{}) {
+	switch v := x.(type) {
+	case *unstructured.Unstructured:
+		sanitize(v.Object, ReferenceOf(v).GroupKind())
+	case runtime.Object:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(v)
+		if err != nil {
+			return
+		}
+		sanitize(content, ReferenceOf(v).GroupKind())
+
+		// Decode into a zero value so that the removed fields are reset
+		sanitized := reflect.New(reflect.TypeOf(v).Elem())
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, sanitized.Interface()); err != nil {
+			return
+		}
+		reflect.ValueOf(v).Elem().Set(sanitized.Elem())
+	}
+	return
+}
+
+// sanitize removes the metadata, status and kind specific fields of the source cluster
+func sanitize(content map[string]interface{}, gk schema.GroupKind) {
+	for _, field := range sanitizedMetadata {
+		unstructured.RemoveNestedField(content, "metadata", field)
+	}
+	for _, annotation := range sanitizedAnnotations {
+		unstructured.RemoveNestedField(content, "metadata", "annotations", annotation)
+	}
+	if annotations, _, _ := unstructured.NestedMap(content, "metadata", "annotations"); len(annotations) == 0 {
+		unstructured.RemoveNestedField(content, "metadata", "annotations")
+	}
+	delete(content, "status")
+
+	if rule, ok := kindRules[gk]; ok {
+		rule(content)
+	}
+}
+
+// sanitizeService removes the cluster IPs and node ports allocated by the source
+// cluster, headless services keep their "None" cluster IP
+func sanitizeService(content map[string]interface{}) {
+	if ip, _, _ := unstructured.NestedString(content, "spec", "clusterIP"); ip != corev1.ClusterIPNone {
+		unstructured.RemoveNestedField(content, "spec", "clusterIP")
+		unstructured.RemoveNestedField(content, "spec", "clusterIPs")
+	}
+	unstructured.RemoveNestedField(content, "spec", "healthCheckNodePort")
+
+	ports, _, _ := unstructured.NestedSlice(content, "spec", "ports")
+	for _, port := range ports {
+		if p, ok := port.(map[string]interface{}); ok {
+			delete(p, "nodePort")
+		}
+	}
+	if ports != nil {
+		unstructured.SetNestedSlice(content, ports, "spec", "ports")
+	}
+}
+
+// sanitizeServiceAccount removes the token secrets generated by the source cluster
+func sanitizeServiceAccount(content map[string]interface{}) {
+	delete(content, "secrets")
+}
+
+// sanitizeStatefulSet removes the status of the volume claim templates
+func sanitizeStatefulSet(content map[string]interface{}) {
+	templates, _, _ := unstructured.NestedSlice(content, "spec", "volumeClaimTemplates")
+	for _, template := range templates {
+		if t, ok := template.(map[string]interface{}); ok {
+			delete(t, "status")
+		}
+	}
+	if templates != nil {
+		unstructured.SetNestedSlice(content, templates, "spec", "volumeClaimTemplates")
+	}
+}
+
+// sanitizePVC removes the binding of the claim to a volume of the source cluster
+func sanitizePVC(content map[string]interface{}) {
+	unstructured.RemoveNestedField(content, "spec", "volumeName")
+	for _, annotation := range pvcBindingAnnotations {
+		unstructured.RemoveNestedField(content, "metadata", "annotations", annotation)
+	}
+}
+
+// sanitizeJob removes the selector generated by the job controller, which generates
+// a new one for the job in the destination
+func sanitizeJob(content map[string]interface{}) {
+	unstructured.RemoveNestedField(content, "spec", "selector")
+	unstructured.RemoveNestedField(content, "spec", "manualSelector")
+	for _, label := range jobControllerLabels {
+		unstructured.RemoveNestedField(content, "metadata", "labels", label)
+		unstructured.RemoveNestedField(content, "spec", "template", "metadata", "labels", label)
+	}
+}
+
+// SwitchNamespace moves a resource from one namespace to another, including
+// the namespace references it holds
+func SwitchNamespace(x interface{}, from string, to string) {
 	switch v := x.(type) {
 	case *corev1.Namespace:
-		input := x.(*corev1.Namespace)
-		input.ResourceVersion = ""
-	case *corev1.ConfigMap:
-		input := x.(*corev1.ConfigMap)
-		input.ResourceVersion = ""
-	case *appv1.Deployment:
-		input := x.(*appv1.Deployment)
-		input.ResourceVersion = ""
-	case *v1beta1.Ingress:
-		input := x.(*v1beta1.Ingress)
-		input.ResourceVersion = ""
+		v.Name = to
+		if v.Labels[nsNameLabel] == from {
+			v.Labels[nsNameLabel] = to
+		}
+		return
 	case *rbacv1.RoleBinding:
-		input := x.(*rbacv1.RoleBinding)
-		input.ResourceVersion = ""
-	case *rbacv1.Role:
-		input := x.(*rbacv1.Role)
-		input.ResourceVersion = ""
-	case *corev1.Secret:
-		input := x.(*corev1.Secret)
-		input.ResourceVersion = ""
-	case *corev1.Service:
-		input := x.(*corev1.Service)
-		input.ResourceVersion, input.Spec.ClusterIP = "", ""
-	default:
-		fmt.Println("In default", v)
+		for i := range v.Subjects {
+			if v.Subjects[i].Namespace == from {
+				v.Subjects[i].Namespace = to
+			}
+		}
+	}
+
+	if obj, ok := x.(metav1.Object); ok {
+		obj.SetNamespace(to)
 	}
 	return
 }
+
+// RewriteStorageClass maps the storage classes requested by the volume claims of a
+// resource to the ones of the destination. The "*" key maps any other class,
+// including the default one when none is set
+func RewriteStorageClass(x interface{}, classes map[string]string) {
+	if len(classes) == 0 {
+		return
+	}
+
+	switch v := x.(type) {
+	case *appv1.StatefulSet:
+		for i := range v.Spec.VolumeClaimTemplates {
+			rewriteClaimStorageClass(&v.Spec.VolumeClaimTemplates[i].Spec, classes)
+		}
+	case *corev1.PersistentVolumeClaim:
+		rewriteClaimStorageClass(&v.Spec, classes)
+	}
+}
+
+func rewriteClaimStorageClass(spec *corev1.PersistentVolumeClaimSpec, classes map[string]string) {
+	class := ""
+	if spec.StorageClassName != nil {
+		class = *spec.StorageClassName
+	}
+
+	to, ok := classes[class]
+	if !ok {
+		to, ok = classes["*"]
+	}
+	if ok {
+		spec.StorageClassName = &to
+	}
+}
+
+// GenerateNSName generates a namespace name in the format <ns>-<user>-<shortid>
+func GenerateNSName(ns string, user string) string {
+	suffix := "-" + rand.String(shortIDLength)
+	name := ns
+	if user = dnsLabel(user); user != "" {
+		name += "-" + user
+	}
+	if len(name)+len(suffix) > validation.DNS1123LabelMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123LabelMaxLength-len(suffix)], "-")
+	}
+	return name + suffix
+}
+
+// dnsLabel lowercases the input and replaces anything not allowed in a
+// DNS-1123 label with a hyphen
+func dnsLabel(s string) string {
+	label := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, s)
+	return strings.Trim(label, "-")
+}
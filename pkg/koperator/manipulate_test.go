@@ -16,13 +16,16 @@ package koperator
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	v1beta1 "k8s.io/api/extensions/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	testclient "k8s.io/client-go/kubernetes/fake"
 )
 
@@ -80,6 +83,148 @@ func TestDeploymentManipulation(t *testing.T) {
 
 }
 
+func TestMetadataSanitization(t *testing.T) {
+
+	now := metav1.Now()
+	generation, replicas := int64(3), int32(3)
+	input := &appv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "unit-test-deployment",
+			ResourceVersion:   "12345",
+			UID:               "unit-test-uid",
+			SelfLink:          "/apis/apps/v1/namespaces/unit-test-ns/deployments/unit-test-deployment",
+			Generation:        generation,
+			CreationTimestamp: now,
+			ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+			OwnerReferences:   []metav1.OwnerReference{{Kind: "Application", Name: "unit-test-app"}},
+			Labels:            map[string]string{"app": "unit-test"},
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				"deployment.kubernetes.io/revision":                "2",
+			},
+		},
+		Spec:   appv1.DeploymentSpec{Replicas: &replicas},
+		Status: appv1.DeploymentStatus{Replicas: 3, ObservedGeneration: generation},
+	}
+
+	ManipulateResource(input)
+
+	if input.ResourceVersion != "" || input.UID != "" || input.SelfLink != "" || input.Generation != 0 {
+		t.Errorf("Error while sanitizing metadata, got %v", input.ObjectMeta)
+	}
+
+	if !input.CreationTimestamp.IsZero() || input.ManagedFields != nil || input.OwnerReferences != nil || input.Annotations != nil {
+		t.Errorf("Error while sanitizing metadata, got %v", input.ObjectMeta)
+	}
+
+	if input.Status.Replicas != 0 || input.Labels["app"] != "unit-test" || *input.Spec.Replicas != replicas {
+		t.Errorf("Error while sanitizing deployment, got %v", input)
+	}
+
+}
+
+func TestStatefulSetManipulation(t *testing.T) {
+
+	standard := "standard"
+	input := &appv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-statefulset", ResourceVersion: "12345", UID: "unit-test-uid"},
+		Spec: appv1.StatefulSetSpec{
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "data"},
+					Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &standard},
+					Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+				},
+				{ObjectMeta: metav1.ObjectMeta{Name: "logs"}},
+			},
+		},
+		Status: appv1.StatefulSetStatus{Replicas: 3},
+	}
+
+	ManipulateResource(input)
+
+	if input.ResourceVersion != "" || input.UID != "" || input.Status.Replicas != 0 {
+		t.Errorf("Error while manipulating statefulset")
+	}
+
+	if input.Spec.VolumeClaimTemplates[0].Status.Phase != "" {
+		t.Errorf("Error while manipulating statefulset volume claim templates")
+	}
+
+	RewriteStorageClass(input, map[string]string{"standard": "gp2"})
+	if *input.Spec.VolumeClaimTemplates[0].Spec.StorageClassName != "gp2" || input.Spec.VolumeClaimTemplates[1].Spec.StorageClassName != nil {
+		t.Errorf("Error while rewriting statefulset storage classes")
+	}
+
+	RewriteStorageClass(input, map[string]string{"*": "fast"})
+	if *input.Spec.VolumeClaimTemplates[0].Spec.StorageClassName != "fast" || *input.Spec.VolumeClaimTemplates[1].Spec.StorageClassName != "fast" {
+		t.Errorf("Error while rewriting statefulset storage classes with a wildcard")
+	}
+
+}
+
+func TestPVCManipulation(t *testing.T) {
+
+	standard := "standard"
+	input := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "unit-test-pvc",
+			ResourceVersion: "12345",
+			Annotations: map[string]string{
+				"pv.kubernetes.io/bind-completed":               "yes",
+				"volume.beta.kubernetes.io/storage-provisioner": "kubernetes.io/gce-pd",
+				"unit-test-annotation":                          "kept",
+			},
+		},
+		Spec:   v1.PersistentVolumeClaimSpec{VolumeName: "pvc-12345", StorageClassName: &standard},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+	}
+
+	ManipulateResource(input)
+
+	if input.ResourceVersion != "" || input.Spec.VolumeName != "" || input.Status.Phase != "" {
+		t.Errorf("Manipulation of PVC is failing")
+	}
+
+	if len(input.Annotations) != 1 || input.Annotations["unit-test-annotation"] != "kept" {
+		t.Errorf("Error while removing PVC binding annotations")
+	}
+
+	RewriteStorageClass(input, map[string]string{"standard": "gp2"})
+	if *input.Spec.StorageClassName != "gp2" {
+		t.Errorf("Error while rewriting PVC storage class")
+	}
+
+}
+
+func TestJobManipulation(t *testing.T) {
+
+	labels := map[string]string{"controller-uid": "12345", "job-name": "unit-test-job", "app": "unit-test"}
+	input := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-job", ResourceVersion: "12345", Labels: labels},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "12345"}},
+			Template: v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"controller-uid": "12345", "app": "unit-test"}}},
+		},
+		Status: batchv1.JobStatus{Succeeded: 1},
+	}
+
+	ManipulateResource(input)
+
+	if input.ResourceVersion != "" || input.Spec.Selector != nil || input.Status.Succeeded != 0 {
+		t.Errorf("Manipulation of Job is failing")
+	}
+
+	if _, ok := input.Labels["controller-uid"]; ok || input.Labels["app"] != "unit-test" {
+		t.Errorf("Error while removing Job controller labels")
+	}
+
+	if _, ok := input.Spec.Template.Labels["controller-uid"]; ok || input.Spec.Template.Labels["app"] != "unit-test" {
+		t.Errorf("Error while removing Job template controller labels")
+	}
+
+}
+
 func TestIngressManipulation(t *testing.T) {
 
 	clientset := testclient.NewSimpleClientset()
@@ -169,3 +314,156 @@ func TestServiceManipulation(t *testing.T) {
 	}
 
 }
+
+func TestServiceSanitization(t *testing.T) {
+
+	input := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-service"},
+		Spec: v1.ServiceSpec{
+			Type:                v1.ServiceTypeLoadBalancer,
+			ClusterIP:           "10.0.0.10",
+			HealthCheckNodePort: 30001,
+			Ports:               []v1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}},
+		},
+		Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}}},
+	}
+
+	ManipulateResource(input)
+
+	if input.Spec.ClusterIP != "" || input.Spec.HealthCheckNodePort != 0 {
+		t.Errorf("Error while removing service cluster IPs, got %v", input.Spec)
+	}
+
+	if input.Spec.Ports[0].NodePort != 0 || input.Spec.Ports[0].Port != 80 || input.Status.LoadBalancer.Ingress != nil {
+		t.Errorf("Error while removing service node ports, got %v", input.Spec)
+	}
+
+	dualStack := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "unit-test-dual-stack"},
+		"spec":       map[string]interface{}{"clusterIP": "10.0.0.11", "clusterIPs": []interface{}{"10.0.0.11", "fd00::11"}},
+	}}
+
+	ManipulateResource(dualStack)
+
+	if spec := dualStack.Object["spec"].(map[string]interface{}); spec["clusterIP"] != nil || spec["clusterIPs"] != nil {
+		t.Errorf("Error while removing service cluster IPs, got %v", spec)
+	}
+
+	headless := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "unit-test-headless"},
+		"spec":       map[string]interface{}{"clusterIP": v1.ClusterIPNone, "clusterIPs": []interface{}{v1.ClusterIPNone}},
+	}}
+
+	ManipulateResource(headless)
+
+	if spec := headless.Object["spec"].(map[string]interface{}); spec["clusterIP"] != v1.ClusterIPNone || spec["clusterIPs"] == nil {
+		t.Errorf("Error while keeping headless service cluster IP, got %v", spec)
+	}
+
+}
+
+func TestSwitchNamespace(t *testing.T) {
+
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ns", Labels: map[string]string{nsNameLabel: "unit-test-ns"}}}
+	SwitchNamespace(ns, "unit-test-ns", "unit-test-target")
+
+	if ns.Name != "unit-test-target" || ns.Labels[nsNameLabel] != "unit-test-target" {
+		t.Errorf("Switching namespace of Namespace is failing")
+	}
+
+	input := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-rbinding", Namespace: "unit-test-ns"},
+		Subjects: []rbacv1.Subject{
+			{Kind: "ServiceAccount", Name: "default", Namespace: "unit-test-ns"},
+			{Kind: "ServiceAccount", Name: "default", Namespace: "kube-system"},
+		},
+	}
+	SwitchNamespace(input, "unit-test-ns", "unit-test-target")
+
+	if input.Namespace != "unit-test-target" {
+		t.Errorf("Switching namespace of RoleBinding is failing")
+	}
+
+	if input.Subjects[0].Namespace != "unit-test-target" || input.Subjects[1].Namespace != "kube-system" {
+		t.Errorf("Switching namespace of RoleBinding subjects is failing")
+	}
+
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-service", Namespace: "unit-test-ns"}}
+	SwitchNamespace(svc, "unit-test-ns", "unit-test-target")
+
+	if svc.Namespace != "unit-test-target" {
+		t.Errorf("Switching namespace of services is failing")
+	}
+
+}
+
+func TestGenerateNSName(t *testing.T) {
+
+	name := GenerateNSName("dev", "John.Doe")
+	if !strings.HasPrefix(name, "dev-john-doe-") || len(name) != len("dev-john-doe-")+shortIDLength {
+		t.Errorf("Unexpected generated namespace name %v", name)
+	}
+
+	if GenerateNSName("dev", "John.Doe") == name {
+		t.Errorf("Generated namespace names are not unique")
+	}
+
+	name = GenerateNSName(strings.Repeat("a", 60), "user")
+	if len(name) > 63 {
+		t.Errorf("Generated namespace name %v is not a valid DNS label", name)
+	}
+
+}
+
+func TestUnstructuredManipulation(t *testing.T) {
+
+	input := newCronTab("unit-test-crontab")
+	input.SetUID("unit-test-uid")
+	input.SetGeneration(2)
+	input.SetAnnotations(map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}", "unit-test-annotation": "kept"})
+	input.Object["spec"] = map[string]interface{}{"cronSpec": "* * * * */5"}
+	input.Object["status"] = map[string]interface{}{"active": true}
+
+	ManipulateResource(input)
+
+	if input.GetResourceVersion() != "" {
+		t.Errorf("Manipulation of unstructured objects is failing")
+	}
+
+	if input.GetUID() != "" || input.GetGeneration() != 0 || input.Object["status"] != nil || input.Object["spec"] == nil {
+		t.Errorf("Error while sanitizing unstructured object, got %v", input.Object)
+	}
+
+	if annotations := input.GetAnnotations(); len(annotations) != 1 || annotations["unit-test-annotation"] != "kept" {
+		t.Errorf("Error while sanitizing unstructured annotations, got %v", annotations)
+	}
+
+}
+
+func TestKindRulesMatchGroup(t *testing.T) {
+
+	input := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "unit-test-app", "namespace": "unit-test-ns"},
+		"spec":       map[string]interface{}{"clusterIP": "10.0.0.1"},
+	}}
+
+	ManipulateResource(input)
+
+	if ip, _, _ := unstructured.NestedString(input.Object, "spec", "clusterIP"); ip != "10.0.0.1" {
+		t.Errorf("Error while sanitizing a Service of another group, got %v", input.Object["spec"])
+	}
+
+	input.SetAPIVersion("v1")
+	ManipulateResource(input)
+
+	if _, found, _ := unstructured.NestedString(input.Object, "spec", "clusterIP"); found {
+		t.Errorf("Error while sanitizing a core Service, got %v", input.Object["spec"])
+	}
+
+}
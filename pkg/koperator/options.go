@@ -14,14 +14,31 @@ limitations under the License.
 package koperator
 
 import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 )
 
+// FieldManager is the manager recorded in managedFields for the fields written by kopy
+const FieldManager = "kopy"
+
 // Options to pass to all the methods
 type Options struct {
-	clientset kubernetes.Interface
-	namespace string
+	clientset      kubernetes.Interface
+	dynamic        dynamic.Interface
+	mapper         meta.RESTMapper
+	namespace      string
+	dryRun         bool
+	forceConflicts bool
+	labelSelector  string
+	fieldSelector  string
+	ingressVersion schema.GroupVersion
+	converter      *Converter
 }
 
 // GetOpts generates required options
@@ -31,8 +48,78 @@ func GetOpts(context *rest.Config, ns string) (*Options, error) {
 		return nil, err
 	}
 
+	dc, err := dynamic.NewForConfig(context)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Options{
 		clientset: cs,
+		dynamic:   dc,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(cs.Discovery())),
 		namespace: ns,
 	}, nil
 }
+
+// Namespace returns the namespace the options are bound to
+func (kOpts *Options) Namespace() string {
+	return kOpts.namespace
+}
+
+// SetDryRun makes all the create, update, apply and delete calls server-side dry-run requests,
+// nothing is persisted in the cluster
+func (kOpts *Options) SetDryRun(dryRun bool) {
+	kOpts.dryRun = dryRun
+}
+
+// SetConverter converts the unstructured objects written into the cluster into a
+// version it serves
+func (kOpts *Options) SetConverter(converter *Converter) {
+	kOpts.converter = converter
+}
+
+// SetForceConflicts makes server-side apply take over the ownership of fields
+// managed by others instead of failing with a conflict
+func (kOpts *Options) SetForceConflicts(force bool) {
+	kOpts.forceConflicts = force
+}
+
+// SetSelectors restricts all the list calls of objects in the namespace to the
+// ones matching the label and field selectors
+func (kOpts *Options) SetSelectors(labelSelector string, fieldSelector string) {
+	kOpts.labelSelector = labelSelector
+	kOpts.fieldSelector = fieldSelector
+}
+
+// listOptions returns the options shared by all the list calls of objects in the namespace
+func (kOpts *Options) listOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: kOpts.labelSelector, FieldSelector: kOpts.fieldSelector}
+}
+
+// createOptions returns the options shared by all the create calls
+func (kOpts *Options) createOptions() metav1.CreateOptions {
+	return metav1.CreateOptions{DryRun: kOpts.dryRunOption(), FieldManager: FieldManager}
+}
+
+// updateOptions returns the options shared by all the update calls
+func (kOpts *Options) updateOptions() metav1.UpdateOptions {
+	return metav1.UpdateOptions{DryRun: kOpts.dryRunOption(), FieldManager: FieldManager}
+}
+
+// patchOptions returns the options shared by all the server-side apply calls
+func (kOpts *Options) patchOptions() metav1.PatchOptions {
+	force := kOpts.forceConflicts
+	return metav1.PatchOptions{DryRun: kOpts.dryRunOption(), FieldManager: FieldManager, Force: &force}
+}
+
+func (kOpts *Options) dryRunOption() []string {
+	if kOpts.dryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+// deleteOptions returns the options shared by all the delete calls
+func (kOpts *Options) deleteOptions() metav1.DeleteOptions {
+	return metav1.DeleteOptions{DryRun: kOpts.dryRunOption()}
+}
@@ -0,0 +1,64 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateOptions(t *testing.T) {
+
+	options := Options{namespace: "unit-test-ns"}
+
+	if len(options.createOptions().DryRun) != 0 {
+		t.Errorf("Create options are dry-run by default")
+	}
+
+	options.SetDryRun(true)
+	dryRun := options.createOptions().DryRun
+	if len(dryRun) != 1 || dryRun[0] != metav1.DryRunAll {
+		t.Errorf("Create options are not dry-run once dry-run is set")
+	}
+
+}
+
+func TestUpdateAndDeleteOptions(t *testing.T) {
+
+	options := Options{namespace: "unit-test-ns"}
+	options.SetDryRun(true)
+
+	if len(options.updateOptions().DryRun) != 1 || len(options.deleteOptions().DryRun) != 1 {
+		t.Errorf("Update and delete options are not dry-run once dry-run is set")
+	}
+
+}
+
+func TestPatchOptions(t *testing.T) {
+
+	options := Options{namespace: "unit-test-ns"}
+
+	patchOptions := options.patchOptions()
+	if patchOptions.FieldManager != FieldManager || patchOptions.Force == nil || *patchOptions.Force {
+		t.Errorf("Unexpected default patch options %v", patchOptions)
+	}
+
+	options.SetForceConflicts(true)
+	if !*options.patchOptions().Force {
+		t.Errorf("Patch options don't force conflicts once force conflicts is set")
+	}
+
+}
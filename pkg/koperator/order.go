@@ -0,0 +1,463 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"sort"
+
+	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// InstallOrder is the order kinds are created in, similar to the one of Helm.
+// Kinds which are not listed, such as custom resources, are created last
+var InstallOrder = []schema.GroupKind{
+	{Kind: "Namespace"},
+	{Group: "networking.k8s.io", Kind: "NetworkPolicy"},
+	{Kind: "ResourceQuota"},
+	{Kind: "LimitRange"},
+	{Group: "policy", Kind: "PodSecurityPolicy"},
+	{Group: "policy", Kind: "PodDisruptionBudget"},
+	{Kind: "ServiceAccount"},
+	{Kind: "Secret"},
+	{Kind: "ConfigMap"},
+	{Group: "storage.k8s.io", Kind: "StorageClass"},
+	{Kind: "PersistentVolume"},
+	{Kind: "PersistentVolumeClaim"},
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"},
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"},
+	{Kind: "Service"},
+	{Group: "apps", Kind: "DaemonSet"},
+	{Kind: "Pod"},
+	{Kind: "ReplicationController"},
+	{Group: "apps", Kind: "ReplicaSet"},
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"},
+	{Group: "apps", Kind: "StatefulSet"},
+	{Group: "batch", Kind: "Job"},
+	{Group: "batch", Kind: "CronJob"},
+	{Group: "networking.k8s.io", Kind: "Ingress"},
+	{Group: "apiregistration.k8s.io", Kind: "APIService"},
+}
+
+// legacyGroups are the groups kinds were served from before moving to their
+// current one, an object has the same identity in both
+var legacyGroups = map[schema.GroupKind]string{
+	{Group: "extensions", Kind: "Ingress"}:           "networking.k8s.io",
+	{Group: "extensions", Kind: "NetworkPolicy"}:     "networking.k8s.io",
+	{Group: "extensions", Kind: "PodSecurityPolicy"}: "policy",
+	{Group: "extensions", Kind: "DaemonSet"}:         "apps",
+	{Group: "extensions", Kind: "Deployment"}:        "apps",
+	{Group: "extensions", Kind: "ReplicaSet"}:        "apps",
+}
+
+// Reference identifies an object in the same namespace
+type Reference struct {
+	Group string
+	Kind  string
+	Name  string
+}
+
+// newReference returns the reference to an object, kinds of a legacy group are
+// moved to their current one
+func newReference(gk schema.GroupKind, name string) Reference {
+	if group, ok := legacyGroups[gk]; ok {
+		gk.Group = group
+	}
+	return Reference{Group: gk.Group, Kind: gk.Kind, Name: name}
+}
+
+// GroupKind returns the group and the kind of the referenced object
+func (r Reference) GroupKind() schema.GroupKind {
+	return schema.GroupKind{Group: r.Group, Kind: r.Kind}
+}
+
+func (r Reference) String() string {
+	if r.Group == "" {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "." + r.Group + "/" + r.Name
+}
+
+// DanglingReference is a reference to an object which is not part of the set being created
+type DanglingReference struct {
+	Object    Reference
+	Reference Reference
+}
+
+// implicitObjects exist in every namespace, references to them are never dangling
+var implicitObjects = map[Reference]bool{
+	{Kind: "ServiceAccount", Name: "default"}:     true,
+	{Kind: "ConfigMap", Name: "kube-root-ca.crt"}: true,
+}
+
+// knownGroupKind returns the group of a kind of the install order, for the
+// references which don't tell their API version
+func knownGroupKind(kind string) schema.GroupKind {
+	for _, gk := range InstallOrder {
+		if gk.Kind == kind {
+			return gk
+		}
+	}
+	return schema.GroupKind{Kind: kind}
+}
+
+// ReferenceOf returns the reference to the given resource
+func ReferenceOf(x interface{}) Reference {
+	var name string
+	if obj, ok := x.(metav1.Object); ok {
+		name = obj.GetName()
+	}
+	return newReference(GroupKindOf(x), name)
+}
+
+// Order groups the resources into tiers which have to be created one after the
+// other. A resource is placed after the kinds before it in the install order and
+// after all the resources it references, resources of the same tier don't depend
+// on each other. References to resources which are not in the set are returned
+// as dangling
+func Order(objects []interface{}) (tiers [][]interface{}, dangling []DanglingReference) {
+	rank := map[schema.GroupKind]int{}
+	for i, gk := range InstallOrder {
+		rank[gk] = i
+	}
+
+	index := map[Reference]int{}
+	for i, x := range objects {
+		index[ReferenceOf(x)] = i
+	}
+
+	deps := make([][]int, len(objects))
+	for i, x := range objects {
+		for _, ref := range References(x) {
+			if j, ok := index[ref]; ok {
+				if j != i {
+					deps[i] = append(deps[i], j)
+				}
+			} else if !implicitObjects[ref] {
+				dangling = append(dangling, DanglingReference{Object: ReferenceOf(x), Reference: ref})
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(objects))
+	level := make([]int, len(objects))
+
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		l, ok := rank[ReferenceOf(objects[i]).GroupKind()]
+		if !ok {
+			l = len(InstallOrder)
+		}
+		for _, j := range deps[i] {
+			switch state[j] {
+			case unvisited:
+				visit(j)
+			case visiting:
+				// Reference cycle, the install order decides
+				continue
+			}
+			if level[j]+1 > l {
+				l = level[j] + 1
+			}
+		}
+		level[i], state[i] = l, visited
+	}
+
+	byLevel := map[int][]interface{}{}
+	for i := range objects {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+	for i, x := range objects {
+		byLevel[level[i]] = append(byLevel[level[i]], x)
+	}
+
+	var levels []int
+	for l := range byLevel {
+		levels = append(levels, l)
+	}
+	sort.Ints(levels)
+	for _, l := range levels {
+		tiers = append(tiers, byLevel[l])
+	}
+	return
+}
+
+// References returns the objects of the same namespace the given resource refers to
+func References(x interface{}) (refs []Reference) {
+	switch v := x.(type) {
+	case *appv1.Deployment:
+		return podSpecReferences(&v.Spec.Template.Spec)
+	case *appv1.StatefulSet:
+		refs = podSpecReferences(&v.Spec.Template.Spec)
+		if v.Spec.ServiceName != "" {
+			refs = append(refs, Reference{Kind: "Service", Name: v.Spec.ServiceName})
+		}
+		return
+	case *batchv1.Job:
+		return podSpecReferences(&v.Spec.Template.Spec)
+	case *rbacv1.RoleBinding:
+		return roleBindingReferences(v)
+	case *networkingv1.Ingress:
+		for _, name := range ingressServices(v) {
+			refs = append(refs, Reference{Kind: "Service", Name: name})
+		}
+		for _, tls := range v.Spec.TLS {
+			if tls.SecretName != "" {
+				refs = append(refs, Reference{Kind: "Secret", Name: tls.SecretName})
+			}
+		}
+		return
+	case *unstructured.Unstructured:
+		return unstructuredReferences(v)
+	}
+	return nil
+}
+
+// podSpecPaths are the paths of the pod spec in the workload kinds
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"PodTemplate":           {"template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+func unstructuredReferences(u *unstructured.Unstructured) (refs []Reference) {
+	if spec := PodSpecOf(u); spec != nil {
+		refs = append(refs, podSpecReferences(spec)...)
+	}
+
+	switch u.GetKind() {
+	case "StatefulSet":
+		if name, _, _ := unstructured.NestedString(u.Object, "spec", "serviceName"); name != "" {
+			refs = append(refs, Reference{Kind: "Service", Name: name})
+		}
+	case "HorizontalPodAutoscaler":
+		apiVersion, _, _ := unstructured.NestedString(u.Object, "spec", "scaleTargetRef", "apiVersion")
+		kind, _, _ := unstructured.NestedString(u.Object, "spec", "scaleTargetRef", "kind")
+		name, _, _ := unstructured.NestedString(u.Object, "spec", "scaleTargetRef", "name")
+		if gv, err := schema.ParseGroupVersion(apiVersion); err == nil && kind != "" && name != "" {
+			gk := gv.WithKind(kind).GroupKind()
+			if apiVersion == "" {
+				gk = knownGroupKind(kind)
+			}
+			refs = append(refs, newReference(gk, name))
+		}
+	case "ServiceAccount":
+		pullSecrets, _, _ := unstructured.NestedSlice(u.Object, "imagePullSecrets")
+		for _, s := range pullSecrets {
+			if name, _, _ := unstructured.NestedString(asMap(s), "name"); name != "" {
+				refs = append(refs, Reference{Kind: "Secret", Name: name})
+			}
+		}
+	case "RoleBinding":
+		var rb rbacv1.RoleBinding
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &rb) == nil {
+			refs = append(refs, roleBindingReferences(&rb)...)
+		}
+	case "Ingress":
+		for _, name := range unstructuredIngressServices(u) {
+			refs = append(refs, Reference{Kind: "Service", Name: name})
+		}
+		tls, _, _ := unstructured.NestedSlice(u.Object, "spec", "tls")
+		for _, t := range tls {
+			if name, _, _ := unstructured.NestedString(asMap(t), "secretName"); name != "" {
+				refs = append(refs, Reference{Kind: "Secret", Name: name})
+			}
+		}
+	}
+	return
+}
+
+// PodSpecOf returns the pod spec of a workload, nil for any other resource
+func PodSpecOf(x interface{}) *corev1.PodSpec {
+	switch v := x.(type) {
+	case *appv1.Deployment:
+		return &v.Spec.Template.Spec
+	case *appv1.StatefulSet:
+		return &v.Spec.Template.Spec
+	case *batchv1.Job:
+		return &v.Spec.Template.Spec
+	case *unstructured.Unstructured:
+		path, ok := podSpecPaths[v.GetKind()]
+		if !ok {
+			return nil
+		}
+		content, found, err := unstructured.NestedMap(v.Object, path...)
+		if !found || err != nil {
+			return nil
+		}
+		var spec corev1.PodSpec
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(content, &spec) != nil {
+			return nil
+		}
+		return &spec
+	}
+	return nil
+}
+
+func podSpecReferences(spec *corev1.PodSpec) (refs []Reference) {
+	add := func(kind string, name string, optional *bool) {
+		if name != "" && (optional == nil || !*optional) {
+			refs = append(refs, Reference{Kind: kind, Name: name})
+		}
+	}
+
+	// Pods run as the default service account unless told otherwise
+	switch {
+	case spec.ServiceAccountName != "":
+		add("ServiceAccount", spec.ServiceAccountName, nil)
+	case spec.DeprecatedServiceAccount != "":
+		add("ServiceAccount", spec.DeprecatedServiceAccount, nil)
+	default:
+		add("ServiceAccount", "default", nil)
+	}
+
+	for _, s := range spec.ImagePullSecrets {
+		add("Secret", s.Name, nil)
+	}
+
+	for _, v := range spec.Volumes {
+		switch {
+		case v.ConfigMap != nil:
+			add("ConfigMap", v.ConfigMap.Name, v.ConfigMap.Optional)
+		case v.Secret != nil:
+			add("Secret", v.Secret.SecretName, v.Secret.Optional)
+		case v.PersistentVolumeClaim != nil:
+			add("PersistentVolumeClaim", v.PersistentVolumeClaim.ClaimName, nil)
+		case v.Projected != nil:
+			for _, source := range v.Projected.Sources {
+				if source.ConfigMap != nil {
+					add("ConfigMap", source.ConfigMap.Name, source.ConfigMap.Optional)
+				}
+				if source.Secret != nil {
+					add("Secret", source.Secret.Name, source.Secret.Optional)
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				add("ConfigMap", ref.Name, ref.Optional)
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				add("Secret", ref.Name, ref.Optional)
+			}
+		}
+		for _, envFrom := range c.EnvFrom {
+			if ref := envFrom.ConfigMapRef; ref != nil {
+				add("ConfigMap", ref.Name, ref.Optional)
+			}
+			if ref := envFrom.SecretRef; ref != nil {
+				add("Secret", ref.Name, ref.Optional)
+			}
+		}
+	}
+	return
+}
+
+func roleBindingReferences(rb *rbacv1.RoleBinding) (refs []Reference) {
+	if rb.RoleRef.Kind == "Role" {
+		refs = append(refs, Reference{Group: rbacv1.GroupName, Kind: "Role", Name: rb.RoleRef.Name})
+	}
+	for _, s := range rb.Subjects {
+		if s.Kind == rbacv1.ServiceAccountKind && (s.Namespace == "" || s.Namespace == rb.Namespace) {
+			refs = append(refs, Reference{Kind: "ServiceAccount", Name: s.Name})
+		}
+	}
+	return
+}
+
+// ingressServices returns the names of the services an ingress routes to
+func ingressServices(ing *networkingv1.Ingress) (names []string) {
+	if b := ing.Spec.DefaultBackend; b != nil && b.Service != nil {
+		names = append(names, b.Service.Name)
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service != nil {
+				names = append(names, p.Backend.Service.Name)
+			}
+		}
+	}
+	return
+}
+
+// unstructuredIngressServices returns the names of the services an ingress of
+// any API version routes to
+func unstructuredIngressServices(u *unstructured.Unstructured) (names []string) {
+	backendService := func(backend map[string]interface{}) {
+		if name, _, _ := unstructured.NestedString(backend, "serviceName"); name != "" {
+			names = append(names, name)
+		}
+		if name, _, _ := unstructured.NestedString(backend, "service", "name"); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	for _, field := range []string{"backend", "defaultBackend"} {
+		if backend, found, _ := unstructured.NestedMap(u.Object, "spec", field); found {
+			backendService(backend)
+		}
+	}
+
+	rules, _, _ := unstructured.NestedSlice(u.Object, "spec", "rules")
+	for _, rule := range rules {
+		paths, _, _ := unstructured.NestedSlice(asMap(rule), "http", "paths")
+		for _, p := range paths {
+			if backend, found, _ := unstructured.NestedMap(asMap(p), "backend"); found {
+				backendService(backend)
+			}
+		}
+	}
+	return
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
@@ -0,0 +1,229 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"testing"
+
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newDeployment(name string) *appv1.Deployment {
+	return &appv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "unit-test-ns"},
+		Spec: appv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					ServiceAccountName: "unit-test-sa",
+					Volumes: []corev1.Volume{
+						{Name: "config", VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "unit-test-configmap"}},
+						}},
+						{Name: "data", VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "unit-test-pvc"},
+						}},
+					},
+					Containers: []corev1.Container{{
+						Name: "app",
+						EnvFrom: []corev1.EnvFromSource{
+							{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "unit-test-secret"}}},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func newServiceAccount(name string) *unstructured.Unstructured {
+	sa := &unstructured.Unstructured{}
+	sa.SetAPIVersion("v1")
+	sa.SetKind("ServiceAccount")
+	sa.SetName(name)
+	sa.SetNamespace("unit-test-ns")
+	return sa
+}
+
+func TestReferences(t *testing.T) {
+
+	refs := References(newDeployment("unit-test-deployment"))
+	expected := []Reference{
+		{Kind: "ServiceAccount", Name: "unit-test-sa"},
+		{Kind: "ConfigMap", Name: "unit-test-configmap"},
+		{Kind: "PersistentVolumeClaim", Name: "unit-test-pvc"},
+		{Kind: "Secret", Name: "unit-test-secret"},
+	}
+	if len(refs) != len(expected) {
+		t.Fatalf("Error while finding deployment references, got %v", refs)
+	}
+	for i := range expected {
+		if refs[i] != expected[i] {
+			t.Errorf("Error while finding deployment references, got %v instead of %v", refs[i], expected[i])
+		}
+	}
+
+	sts, err := ToUnstructured(&appv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-sts", Namespace: "unit-test-ns"},
+		Spec: appv1.StatefulSetSpec{
+			ServiceName: "unit-test-headless",
+			Template:    newDeployment("").Spec.Template,
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	refs = References(sts)
+	if len(refs) != 5 || refs[4] != (Reference{Kind: "Service", Name: "unit-test-headless"}) {
+		t.Errorf("Error while finding statefulset references, got %v", refs)
+	}
+
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-rb", Namespace: "unit-test-ns"},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "unit-test-role"},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: "unit-test-sa"},
+			{Kind: rbacv1.ServiceAccountKind, Name: "other-sa", Namespace: "other-ns"},
+			{Kind: rbacv1.UserKind, Name: "unit-test-user"},
+		},
+	}
+	refs = References(rb)
+	if len(refs) != 2 || refs[0].Kind != "Role" || refs[1].Name != "unit-test-sa" {
+		t.Errorf("Error while finding rolebinding references, got %v", refs)
+	}
+
+	ing := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "Ingress",
+		"metadata":   map[string]interface{}{"name": "unit-test-ingress"},
+		"spec": map[string]interface{}{
+			"defaultBackend": map[string]interface{}{"service": map[string]interface{}{"name": "unit-test-default"}},
+			"rules": []interface{}{map[string]interface{}{
+				"http": map[string]interface{}{"paths": []interface{}{
+					map[string]interface{}{"backend": map[string]interface{}{"service": map[string]interface{}{"name": "unit-test-svc"}}},
+				}},
+			}},
+			"tls": []interface{}{map[string]interface{}{"secretName": "unit-test-tls"}},
+		},
+	}}
+	refs = References(ing)
+	if len(refs) != 3 || refs[0].Name != "unit-test-default" || refs[1].Name != "unit-test-svc" || refs[2].Kind != "Secret" {
+		t.Errorf("Error while finding ingress references, got %v", refs)
+	}
+
+}
+
+func TestOrder(t *testing.T) {
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress", Namespace: "unit-test-ns"},
+		Spec:       networkingv1.IngressSpec{DefaultBackend: &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "unit-test-svc"}}},
+	}
+	deployment := newDeployment("unit-test-deployment")
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-configmap", Namespace: "unit-test-ns"}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-svc", Namespace: "unit-test-ns"}}
+	sa := newServiceAccount("unit-test-sa")
+	cronTab := newCronTab("unit-test-crontab")
+
+	tiers, dangling := Order([]interface{}{cronTab, ingress, deployment, service, configMap, sa})
+
+	var order []string
+	for _, tier := range tiers {
+		for _, x := range tier {
+			order = append(order, KindOf(x))
+		}
+	}
+	expected := []string{"ServiceAccount", "ConfigMap", "Service", "Deployment", "Ingress", "CronTab"}
+	if len(order) != len(expected) {
+		t.Fatalf("Error while ordering resources, got %v", order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("Error while ordering resources, got %v instead of %v", order, expected)
+			break
+		}
+	}
+
+	if len(dangling) != 2 {
+		t.Fatalf("Error while finding dangling references, got %v", dangling)
+	}
+	if dangling[0].Object.Name != "unit-test-deployment" || dangling[0].Reference.Kind != "PersistentVolumeClaim" ||
+		dangling[1].Reference.Kind != "Secret" {
+		t.Errorf("Error while finding dangling references, got %v", dangling)
+	}
+
+}
+
+func TestOrderReferencesFirst(t *testing.T) {
+
+	hpa := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling/v1",
+		"kind":       "HorizontalPodAutoscaler",
+		"metadata":   map[string]interface{}{"name": "unit-test-hpa", "namespace": "unit-test-ns"},
+		"spec": map[string]interface{}{
+			"scaleTargetRef": map[string]interface{}{"kind": "StatefulSet", "name": "unit-test-sts"},
+		},
+	}}
+	sts := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "StatefulSet",
+		"metadata":   map[string]interface{}{"name": "unit-test-sts", "namespace": "unit-test-ns"},
+	}}
+
+	tiers, dangling := Order([]interface{}{hpa, sts})
+	if len(dangling) != 0 {
+		t.Errorf("Error while finding dangling references, got %v", dangling)
+	}
+	if len(tiers) != 2 || tiers[0][0] != sts || tiers[1][0] != hpa {
+		t.Errorf("Error while ordering referenced resources first")
+	}
+
+}
+
+func TestReferenceOfGroups(t *testing.T) {
+
+	// A Knative Service owning a core Service of the same name
+	knative := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "unit-test-app"},
+	}}
+	core := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-app"}}
+	if ReferenceOf(knative) == ReferenceOf(core) {
+		t.Errorf("Error while referencing objects of different groups, both are %v", ReferenceOf(core))
+	}
+
+	// Custom resources are created after the kinds of the install order
+	tiers, _ := Order([]interface{}{knative, core})
+	if len(tiers) != 2 || tiers[0][0] != core {
+		t.Errorf("Error while ordering a custom resource sharing the kind of a core one, got %v", tiers)
+	}
+
+	// Ingresses of the legacy group are the same objects
+	legacy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "extensions/v1beta1",
+		"kind":       "Ingress",
+		"metadata":   map[string]interface{}{"name": "unit-test-ingress"},
+	}}
+	current := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress"}}
+	if ReferenceOf(legacy) != ReferenceOf(current) {
+		t.Errorf("Error while referencing an Ingress of the legacy group, got %v and %v", ReferenceOf(legacy), ReferenceOf(current))
+	}
+
+}
@@ -0,0 +1,170 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"fmt"
+
+	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// immutableFields are the fields allocated by the cluster, or sanitized and
+// rewritten while copying, which can't be changed once the object is created
+var immutableFields = map[schema.GroupKind][][]string{
+	{Group: "", Kind: "Service"}: {
+		{"spec", "clusterIP"},
+		{"spec", "clusterIPs"},
+	},
+	{Group: "", Kind: "PersistentVolumeClaim"}: {
+		{"spec", "volumeName"},
+		{"spec", "storageClassName"},
+	},
+	{Group: "apps", Kind: "StatefulSet"}: {
+		{"spec", "volumeClaimTemplates"},
+	},
+	{Group: "batch", Kind: "Job"}: {
+		{"spec", "selector"},
+		{"spec", "manualSelector"},
+		{"spec", "template", "metadata", "labels"},
+	},
+}
+
+// CreateResource creates a typed resource with its typed client, and any
+// other resource through the dynamic client
+func (kOpts *Options) CreateResource(x interface{}) (err error) {
+	switch v := x.(type) {
+	case *corev1.Namespace:
+		_, err = kOpts.CreateNS(v)
+	case *corev1.ConfigMap:
+		_, err = kOpts.CreateConfigMap(v)
+	case *appv1.Deployment:
+		_, err = kOpts.CreateDeployment(v)
+	case *appv1.StatefulSet:
+		_, err = kOpts.CreateStatefulSet(v)
+	case *networkingv1.Ingress:
+		_, err = kOpts.CreateIngress(v)
+	case *netv1beta1.Ingress, *extv1beta1.Ingress:
+		_, err = kOpts.createServedIngress(v)
+	case *rbacv1.RoleBinding:
+		_, err = kOpts.CreateRBinding(v)
+	case *rbacv1.Role:
+		_, err = kOpts.CreateRole(v)
+	case *corev1.Secret:
+		_, err = kOpts.CreateSecret(v)
+	case *corev1.Service:
+		_, err = kOpts.CreateSVC(v)
+	case *corev1.PersistentVolumeClaim:
+		_, err = kOpts.CreatePVC(v)
+	case *batchv1.Job:
+		_, err = kOpts.CreateJob(v)
+	case *unstructured.Unstructured:
+		_, err = kOpts.CreateUnstructured(v)
+	default:
+		err = fmt.Errorf("unsupported resource type %T", x)
+	}
+	return
+}
+
+// UpdateResource overwrites the object in the cluster with the given resource.
+// Fields which can't be changed are carried over from the existing object
+func (kOpts *Options) UpdateResource(x interface{}) (err error) {
+	obj, err := ToUnstructured(x)
+	if err != nil {
+		return
+	}
+
+	live, err := kOpts.GetUnstructuredObject(obj)
+	if err != nil {
+		return
+	}
+
+	obj = obj.DeepCopy()
+	obj.SetResourceVersion(live.GetResourceVersion())
+	for _, path := range immutableFields[obj.GroupVersionKind().GroupKind()] {
+		value, found, _ := unstructured.NestedFieldNoCopy(live.Object, path...)
+		if !found {
+			unstructured.RemoveNestedField(obj.Object, path...)
+			continue
+		}
+		if err = unstructured.SetNestedField(obj.Object, value, path...); err != nil {
+			return
+		}
+	}
+
+	_, err = kOpts.UpdateUnstructured(obj)
+	return
+}
+
+// ApplyResource applies the given resource with server-side apply, creating it
+// when it doesn't exist
+func (kOpts *Options) ApplyResource(x interface{}) (err error) {
+	obj, err := ToUnstructured(x)
+	if err != nil {
+		return
+	}
+	_, err = kOpts.ApplyUnstructured(obj)
+	return
+}
+
+// ExistsResource tells if an object with the kind and name of the given resource exists
+func (kOpts *Options) ExistsResource(x interface{}) (bool, error) {
+	obj, err := ToUnstructured(x)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = kOpts.GetUnstructuredObject(obj)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GroupKindOf returns the group and the kind of a typed or unstructured resource
+func GroupKindOf(x interface{}) schema.GroupKind {
+	if u, ok := x.(*unstructured.Unstructured); ok {
+		return u.GroupVersionKind().GroupKind()
+	}
+	if obj, ok := x.(runtime.Object); ok {
+		if gvks, _, err := scheme.Scheme.ObjectKinds(obj); err == nil && len(gvks) > 0 {
+			return gvks[0].GroupKind()
+		}
+	}
+	return schema.GroupKind{Kind: fmt.Sprintf("%T", x)}
+}
+
+// KindOf returns the kind of a typed or unstructured resource
+func KindOf(x interface{}) string {
+	if u, ok := x.(*unstructured.Unstructured); ok {
+		return u.GetKind()
+	}
+	if obj, ok := x.(runtime.Object); ok {
+		if gvks, _, err := scheme.Scheme.ObjectKinds(obj); err == nil && len(gvks) > 0 {
+			return gvks[0].Kind
+		}
+	}
+	return fmt.Sprintf("%T", x)
+}
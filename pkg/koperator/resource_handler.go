@@ -16,22 +16,29 @@ package koperator
 
 import (
 	"context"
+	"time"
 
 	appv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	v1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
+const nsPollInterval = 2 * time.Second
+
 // GetDeployments returns all the Deployments in the given namespace and clientset
 func (kOpts *Options) GetDeployments() (result *appv1.DeploymentList, err error) {
 	result, err = kOpts.clientset.
 		AppsV1().
 		Deployments(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+		List(context.TODO(), kOpts.listOptions())
 	return
 }
 
@@ -40,7 +47,7 @@ func (kOpts *Options) DeleteDeployment(name string) (err error) {
 	err = kOpts.clientset.
 		AppsV1().
 		Deployments(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
 	return
 }
 
@@ -49,7 +56,34 @@ func (kOpts *Options) CreateDeployment(deployment *appv1.Deployment) (result *ap
 	result, err = kOpts.clientset.
 		AppsV1().
 		Deployments(kOpts.namespace).
-		Create(context.TODO(), deployment, metav1.CreateOptions{})
+		Create(context.TODO(), deployment, kOpts.createOptions())
+	return
+}
+
+// GetStatefulSets returns all the StatefulSets in the given namespace and clientset
+func (kOpts *Options) GetStatefulSets() (result *appv1.StatefulSetList, err error) {
+	result, err = kOpts.clientset.
+		AppsV1().
+		StatefulSets(kOpts.namespace).
+		List(context.TODO(), kOpts.listOptions())
+	return
+}
+
+// DeleteStatefulSet is a method to delete a provided statefulset name
+func (kOpts *Options) DeleteStatefulSet(name string) (err error) {
+	err = kOpts.clientset.
+		AppsV1().
+		StatefulSets(kOpts.namespace).
+		Delete(context.TODO(), name, kOpts.deleteOptions())
+	return
+}
+
+// CreateStatefulSet method to create a statefulset
+func (kOpts *Options) CreateStatefulSet(statefulSet *appv1.StatefulSet) (result *appv1.StatefulSet, err error) {
+	result, err = kOpts.clientset.
+		AppsV1().
+		StatefulSets(kOpts.namespace).
+		Create(context.TODO(), statefulSet, kOpts.createOptions())
 	return
 }
 
@@ -58,7 +92,7 @@ func (kOpts *Options) GetConfigMaps() (result *corev1.ConfigMapList, err error)
 	result, err = kOpts.clientset.
 		CoreV1().
 		ConfigMaps(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+		List(context.TODO(), kOpts.listOptions())
 	return
 }
 
@@ -67,7 +101,7 @@ func (kOpts *Options) DeleteConfigMap(name string) (err error) {
 	err = kOpts.clientset.
 		CoreV1().
 		ConfigMaps(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
 	return
 }
 
@@ -76,34 +110,90 @@ func (kOpts *Options) CreateConfigMap(configmap *corev1.ConfigMap) (result *core
 	result, err = kOpts.clientset.
 		CoreV1().
 		ConfigMaps(kOpts.namespace).
-		Create(context.TODO(), configmap, metav1.CreateOptions{})
+		Create(context.TODO(), configmap, kOpts.createOptions())
 	return
 }
 
-// GetIngress returns all the Ingresses in the given namespace and clientset
-func (kOpts *Options) GetIngress() (result *v1beta1.IngressList, err error) {
-	result, err = kOpts.clientset.
-		ExtensionsV1beta1().
-		Ingresses(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+// GetIngress returns all the Ingresses in the given namespace and clientset, read
+// with the version served by the cluster and converted to networking.k8s.io/v1
+func (kOpts *Options) GetIngress() (result *networkingv1.IngressList, err error) {
+	version, err := kOpts.IngressVersion()
+	if err != nil {
+		return
+	}
+
+	var items []interface{}
+	switch version {
+	case networkingv1.SchemeGroupVersion:
+		result, err = kOpts.clientset.
+			NetworkingV1().
+			Ingresses(kOpts.namespace).
+			List(context.TODO(), kOpts.listOptions())
+		return
+	case netv1beta1.SchemeGroupVersion:
+		var list *netv1beta1.IngressList
+		if list, err = kOpts.clientset.NetworkingV1beta1().Ingresses(kOpts.namespace).List(context.TODO(), kOpts.listOptions()); err != nil {
+			return
+		}
+		for i := range list.Items {
+			items = append(items, &list.Items[i])
+		}
+	default:
+		var list *v1beta1.IngressList
+		if list, err = kOpts.clientset.ExtensionsV1beta1().Ingresses(kOpts.namespace).List(context.TODO(), kOpts.listOptions()); err != nil {
+			return
+		}
+		for i := range list.Items {
+			items = append(items, &list.Items[i])
+		}
+	}
+
+	result = &networkingv1.IngressList{}
+	for _, item := range items {
+		var ingress interface{}
+		if ingress, err = ConvertIngress(item, networkingv1.SchemeGroupVersion); err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, *ingress.(*networkingv1.Ingress))
+	}
 	return
 }
 
 // DeleteIngress method deletes an ingress with the given name
 func (kOpts *Options) DeleteIngress(name string) (err error) {
-	err = kOpts.clientset.
-		ExtensionsV1beta1().
-		Ingresses(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+	version, err := kOpts.IngressVersion()
+	if err != nil {
+		return
+	}
+
+	switch version {
+	case networkingv1.SchemeGroupVersion:
+		err = kOpts.clientset.NetworkingV1().Ingresses(kOpts.namespace).Delete(context.TODO(), name, kOpts.deleteOptions())
+	case netv1beta1.SchemeGroupVersion:
+		err = kOpts.clientset.NetworkingV1beta1().Ingresses(kOpts.namespace).Delete(context.TODO(), name, kOpts.deleteOptions())
+	default:
+		err = kOpts.clientset.ExtensionsV1beta1().Ingresses(kOpts.namespace).Delete(context.TODO(), name, kOpts.deleteOptions())
+	}
 	return
 }
 
-// CreateIngress method to create an ingress
-func (kOpts *Options) CreateIngress(ingress *v1beta1.Ingress) (result *v1beta1.Ingress, err error) {
-	result, err = kOpts.clientset.
-		ExtensionsV1beta1().
-		Ingresses(kOpts.namespace).
-		Create(context.TODO(), ingress, metav1.CreateOptions{})
+// CreateIngress method to create an ingress, converted to the version served by the cluster
+func (kOpts *Options) CreateIngress(ingress *networkingv1.Ingress) (result *networkingv1.Ingress, err error) {
+	served, err := kOpts.ServedVersion(ingress)
+	if err != nil {
+		return
+	}
+
+	created, err := kOpts.createServedIngress(served)
+	if err != nil {
+		return
+	}
+
+	converted, err := ConvertIngress(created, networkingv1.SchemeGroupVersion)
+	if err != nil {
+		return
+	}
+	result = converted.(*networkingv1.Ingress)
 	return
 }
 
@@ -116,12 +206,33 @@ func (kOpts *Options) GetNS() (result *corev1.Namespace, err error) {
 	return
 }
 
+// GetNamespaces returns all the namespaces matching the given label selector
+func (kOpts *Options) GetNamespaces(selector string) (result *corev1.NamespaceList, err error) {
+	result, err = kOpts.clientset.
+		CoreV1().
+		Namespaces().
+		List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	return
+}
+
 // DeleteNS method to delete a namespace
 func (kOpts *Options) DeleteNS(name string) (err error) {
 	err = kOpts.clientset.
 		CoreV1().
 		Namespaces().
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
+	return
+}
+
+// WaitForNSDeletion waits until the namespace is completely removed from the cluster
+func (kOpts *Options) WaitForNSDeletion(timeout time.Duration) (err error) {
+	err = wait.PollImmediate(nsPollInterval, timeout, func() (bool, error) {
+		_, err := kOpts.GetNS()
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
 	return
 }
 
@@ -130,7 +241,7 @@ func (kOpts *Options) CreateNS(namespace *v1.Namespace) (result *corev1.Namespac
 	result, err = kOpts.clientset.
 		CoreV1().
 		Namespaces().
-		Create(context.TODO(), namespace, metav1.CreateOptions{})
+		Create(context.TODO(), namespace, kOpts.createOptions())
 	return
 }
 
@@ -139,7 +250,7 @@ func (kOpts *Options) GetRoleBindings() (result *rbacv1.RoleBindingList, err err
 	result, err = kOpts.clientset.
 		RbacV1().
 		RoleBindings(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+		List(context.TODO(), kOpts.listOptions())
 	return
 }
 
@@ -148,7 +259,7 @@ func (kOpts *Options) DeleteRBinding(name string) (err error) {
 	err = kOpts.clientset.
 		RbacV1().
 		RoleBindings(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
 	return
 }
 
@@ -157,7 +268,7 @@ func (kOpts *Options) CreateRBinding(rBinding *rbacv1.RoleBinding) (result *rbac
 	result, err = kOpts.clientset.
 		RbacV1().
 		RoleBindings(kOpts.namespace).
-		Create(context.TODO(), rBinding, metav1.CreateOptions{})
+		Create(context.TODO(), rBinding, kOpts.createOptions())
 	return
 }
 
@@ -166,7 +277,7 @@ func (kOpts *Options) GetRoles() (result *rbacv1.RoleList, err error) {
 	result, err = kOpts.clientset.
 		RbacV1().
 		Roles(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+		List(context.TODO(), kOpts.listOptions())
 	return
 }
 
@@ -175,7 +286,7 @@ func (kOpts *Options) DeleteRole(name string) (err error) {
 	err = kOpts.clientset.
 		RbacV1().
 		Roles(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
 	return
 }
 
@@ -184,7 +295,7 @@ func (kOpts *Options) CreateRole(role *rbacv1.Role) (result *rbacv1.Role, err er
 	result, err = kOpts.clientset.
 		RbacV1().
 		Roles(kOpts.namespace).
-		Create(context.TODO(), role, metav1.CreateOptions{})
+		Create(context.TODO(), role, kOpts.createOptions())
 	return
 }
 
@@ -193,7 +304,7 @@ func (kOpts *Options) GetSecrets() (result *corev1.SecretList, err error) {
 	result, err = kOpts.clientset.
 		CoreV1().
 		Secrets(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+		List(context.TODO(), kOpts.listOptions())
 	return
 }
 
@@ -202,7 +313,7 @@ func (kOpts *Options) DeleteSecret(name string) (err error) {
 	err = kOpts.clientset.
 		CoreV1().
 		Secrets(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
 	return
 }
 
@@ -211,7 +322,7 @@ func (kOpts *Options) CreateSecret(secret *corev1.Secret) (result *corev1.Secret
 	result, err = kOpts.clientset.
 		CoreV1().
 		Secrets(kOpts.namespace).
-		Create(context.TODO(), secret, metav1.CreateOptions{})
+		Create(context.TODO(), secret, kOpts.createOptions())
 	return
 }
 
@@ -220,7 +331,7 @@ func (kOpts *Options) GetSVC() (result *corev1.ServiceList, err error) {
 	result, err = kOpts.clientset.
 		CoreV1().
 		Services(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+		List(context.TODO(), kOpts.listOptions())
 	return
 }
 
@@ -229,7 +340,7 @@ func (kOpts *Options) DeleteSVC(name string) (err error) {
 	err = kOpts.clientset.
 		CoreV1().
 		Services(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
 	return
 }
 
@@ -238,7 +349,7 @@ func (kOpts *Options) CreateSVC(service *corev1.Service) (result *corev1.Service
 	result, err = kOpts.clientset.
 		CoreV1().
 		Services(kOpts.namespace).
-		Create(context.TODO(), service, metav1.CreateOptions{})
+		Create(context.TODO(), service, kOpts.createOptions())
 	return
 }
 
@@ -247,7 +358,7 @@ func (kOpts *Options) GetPVC() (result *corev1.PersistentVolumeClaimList, err er
 	result, err = kOpts.clientset.
 		CoreV1().
 		PersistentVolumeClaims(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+		List(context.TODO(), kOpts.listOptions())
 	return
 }
 
@@ -256,7 +367,7 @@ func (kOpts *Options) DeletePVC(name string) (err error) {
 	err = kOpts.clientset.
 		CoreV1().
 		PersistentVolumeClaims(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
 	return
 }
 
@@ -265,7 +376,7 @@ func (kOpts *Options) CreatePVC(pvc *corev1.PersistentVolumeClaim) (result *core
 	result, err = kOpts.clientset.
 		CoreV1().
 		PersistentVolumeClaims(kOpts.namespace).
-		Create(context.TODO(), pvc, metav1.CreateOptions{})
+		Create(context.TODO(), pvc, kOpts.createOptions())
 	return
 }
 
@@ -274,7 +385,7 @@ func (kOpts *Options) GetJob() (result *batchv1.JobList, err error) {
 	result, err = kOpts.clientset.
 		BatchV1().
 		Jobs(kOpts.namespace).
-		List(context.TODO(), metav1.ListOptions{})
+		List(context.TODO(), kOpts.listOptions())
 	return
 }
 
@@ -283,7 +394,7 @@ func (kOpts *Options) DeleteJob(name string) (err error) {
 	err = kOpts.clientset.
 		BatchV1().
 		Jobs(kOpts.namespace).
-		Delete(context.TODO(), name, metav1.DeleteOptions{})
+		Delete(context.TODO(), name, kOpts.deleteOptions())
 	return
 }
 
@@ -292,6 +403,6 @@ func (kOpts *Options) CreateJob(job *batchv1.Job) (result *batchv1.Job, err erro
 	result, err = kOpts.clientset.
 		BatchV1().
 		Jobs(kOpts.namespace).
-		Create(context.TODO(), job, metav1.CreateOptions{})
+		Create(context.TODO(), job, kOpts.createOptions())
 	return
 }
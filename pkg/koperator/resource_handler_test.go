@@ -17,13 +17,17 @@ package koperator
 import (
 	"context"
 	"testing"
+	"time"
 
 	appv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	v1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	testclient "k8s.io/client-go/kubernetes/fake"
 )
 
@@ -113,6 +117,90 @@ func TestCreateDeployment(t *testing.T) {
 
 }
 
+func TestGetStatefulSet(t *testing.T) {
+
+	cs := testclient.NewSimpleClientset()
+	input := &appv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-statefulset", ResourceVersion: "12345"}}
+
+	options := Options{
+		clientset: cs,
+		namespace: "unit-test-ns",
+	}
+
+	_, err := cs.AppsV1().StatefulSets("unit-test-ns").Create(context.TODO(), input, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	output, err := options.GetStatefulSets()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(output.Items) != 1 || output.Items[0].Name != "unit-test-statefulset" {
+		t.Errorf("Error while getting statefulsets")
+	}
+
+}
+
+func TestDeleteStatefulSet(t *testing.T) {
+
+	cs := testclient.NewSimpleClientset()
+	input := &appv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-statefulset", ResourceVersion: "12345"}}
+
+	options := Options{
+		clientset: cs,
+		namespace: "unit-test-ns",
+	}
+
+	_, err := cs.AppsV1().StatefulSets("unit-test-ns").Create(context.TODO(), input, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = options.DeleteStatefulSet("unit-test-statefulset")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = options.DeleteStatefulSet("unit-test-statefulset-1")
+	if err == nil {
+		t.Errorf("Error while deleting unexistence statefulset")
+	}
+
+}
+
+func TestCreateStatefulSet(t *testing.T) {
+
+	cs := testclient.NewSimpleClientset()
+	input := &appv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-statefulset", ResourceVersion: "12345"}}
+
+	options := Options{
+		clientset: cs,
+		namespace: "unit-test-ns",
+	}
+
+	_, err := options.CreateStatefulSet(input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	output, err := cs.AppsV1().StatefulSets(options.namespace).Get(context.TODO(), "unit-test-statefulset", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if output.Name != "unit-test-statefulset" {
+		t.Errorf("Error while retrieving created statefulset")
+	}
+
+	_, err = options.CreateStatefulSet(input)
+	if err == nil {
+		t.Fatal("Error while creating duplicate statefulset")
+	}
+
+}
+
 func TestGetConfigMap(t *testing.T) {
 
 	cs := testclient.NewSimpleClientset()
@@ -199,10 +287,22 @@ func TestCreateConfigMap(t *testing.T) {
 
 }
 
+// newIngressClientset returns a clientset serving Ingresses with the given version
+func newIngressClientset(version string) *testclient.Clientset {
+	cs := testclient.NewSimpleClientset()
+	cs.Resources = []*metav1.APIResourceList{
+		{GroupVersion: version, APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}}},
+	}
+	return cs
+}
+
 func TestGetIngress(t *testing.T) {
 
-	cs := testclient.NewSimpleClientset()
-	input := &v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress", ResourceVersion: "12345"}}
+	cs := newIngressClientset("extensions/v1beta1")
+	input := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress", ResourceVersion: "12345"},
+		Spec:       v1beta1.IngressSpec{Backend: &v1beta1.IngressBackend{ServiceName: "unit-test-svc", ServicePort: intstr.FromInt(80)}},
+	}
 
 	options := Options{
 		clientset: cs,
@@ -223,21 +323,24 @@ func TestGetIngress(t *testing.T) {
 		if v.Name != "unit-test-ingress" {
 			t.Errorf("Error while getting ingress")
 		}
+		if b := v.Spec.DefaultBackend; b == nil || b.Service.Name != "unit-test-svc" || b.Service.Port.Number != 80 {
+			t.Errorf("Error while converting ingress, got %v", v.Spec)
+		}
 	}
 
 }
 
 func TestDeleteIngress(t *testing.T) {
 
-	cs := testclient.NewSimpleClientset()
-	input := &v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress", ResourceVersion: "12345"}}
+	cs := newIngressClientset("networking.k8s.io/v1beta1")
+	input := &netv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress", ResourceVersion: "12345"}}
 
 	options := Options{
 		clientset: cs,
 		namespace: "unit-test-ns",
 	}
 
-	_, err := cs.ExtensionsV1beta1().Ingresses("unit-test-ns").Create(context.TODO(), input, metav1.CreateOptions{})
+	_, err := cs.NetworkingV1beta1().Ingresses("unit-test-ns").Create(context.TODO(), input, metav1.CreateOptions{})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -256,8 +359,8 @@ func TestDeleteIngress(t *testing.T) {
 
 func TestCreateIngress(t *testing.T) {
 
-	cs := testclient.NewSimpleClientset()
-	input := &v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress", ResourceVersion: "12345"}}
+	cs := newIngressClientset("networking.k8s.io/v1")
+	input := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress", ResourceVersion: "12345"}}
 
 	options := Options{
 		clientset: cs,
@@ -269,7 +372,7 @@ func TestCreateIngress(t *testing.T) {
 		t.Fatal(err.Error())
 	}
 
-	output, err := cs.ExtensionsV1beta1().Ingresses("unit-test-ns").Get(context.TODO(), "unit-test-ingress", metav1.GetOptions{})
+	output, err := cs.NetworkingV1().Ingresses("unit-test-ns").Get(context.TODO(), "unit-test-ingress", metav1.GetOptions{})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -278,13 +381,46 @@ func TestCreateIngress(t *testing.T) {
 		t.Errorf("Error while retreiving created ingress")
 	}
 
-	_, err = cs.ExtensionsV1beta1().Ingresses("unit-test-ns").Create(context.TODO(), input, metav1.CreateOptions{})
+	_, err = cs.NetworkingV1().Ingresses("unit-test-ns").Create(context.TODO(), input, metav1.CreateOptions{})
 	if err == nil {
 		t.Errorf("Error while creating duplicate ingress")
 	}
 
 }
 
+func TestCreateIngressServedVersion(t *testing.T) {
+
+	cs := newIngressClientset("extensions/v1beta1")
+	className := "nginx"
+	input := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-ingress"},
+		Spec:       networkingv1.IngressSpec{IngressClassName: &className},
+	}
+
+	options := Options{
+		clientset: cs,
+		namespace: "unit-test-ns",
+	}
+
+	if err := options.ResolveIngressVersion(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := options.CreateIngress(input); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	output, err := cs.ExtensionsV1beta1().Ingresses("unit-test-ns").Get(context.TODO(), "unit-test-ingress", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if output.Annotations["kubernetes.io/ingress.class"] != "nginx" || output.Spec.IngressClassName != nil {
+		t.Errorf("Error while creating ingress with the served version, got %v", output)
+	}
+
+}
+
 func TestGetNS(t *testing.T) {
 
 	cs := testclient.NewSimpleClientset()
@@ -311,6 +447,28 @@ func TestGetNS(t *testing.T) {
 
 }
 
+func TestGetNamespaces(t *testing.T) {
+
+	cs := testclient.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-payments", Labels: map[string]string{"team": "payments"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-orders", Labels: map[string]string{"team": "orders"}}},
+	)
+
+	options := Options{
+		clientset: cs,
+	}
+
+	output, err := options.GetNamespaces("team=payments")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(output.Items) != 1 || output.Items[0].Name != "unit-test-payments" {
+		t.Errorf("Error while getting namespaces by selector")
+	}
+
+}
+
 func TestDeleteNS(t *testing.T) {
 
 	cs := testclient.NewSimpleClientset()
@@ -338,6 +496,33 @@ func TestDeleteNS(t *testing.T) {
 
 }
 
+func TestWaitForNSDeletion(t *testing.T) {
+
+	cs := testclient.NewSimpleClientset()
+
+	options := Options{
+		clientset: cs,
+		namespace: "unit-test-namespace",
+	}
+
+	err := options.WaitForNSDeletion(time.Second)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	input := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-namespace"}}
+	_, err = cs.CoreV1().Namespaces().Create(context.TODO(), input, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = options.WaitForNSDeletion(10 * time.Millisecond)
+	if err == nil {
+		t.Errorf("Error while waiting for existing namespace")
+	}
+
+}
+
 func TestCreateNS(t *testing.T) {
 
 	cs := testclient.NewSimpleClientset()
@@ -883,3 +1068,27 @@ func TestCreateJob(t *testing.T) {
 	}
 
 }
+
+func TestGetWithSelectors(t *testing.T) {
+
+	cs := testclient.NewSimpleClientset(
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-checkout", Namespace: "unit-test-ns", Labels: map[string]string{"app": "checkout"}}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-cart", Namespace: "unit-test-ns", Labels: map[string]string{"app": "cart"}}},
+	)
+
+	options := Options{
+		clientset: cs,
+		namespace: "unit-test-ns",
+	}
+	options.SetSelectors("app=checkout", "")
+
+	output, err := options.GetConfigMaps()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(output.Items) != 1 || output.Items[0].Name != "unit-test-checkout" {
+		t.Errorf("Error while getting configmaps with a label selector")
+	}
+
+}
@@ -0,0 +1,193 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateResource(t *testing.T) {
+
+	options := newUnstructuredOptions()
+
+	err := options.CreateResource(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-configmap"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, err = options.clientset.CoreV1().ConfigMaps("unit-test-ns").Get(context.TODO(), "unit-test-configmap", metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("Error while creating typed resource")
+	}
+
+	err = options.CreateResource(newCronTab("unit-test-crontab"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, err = options.dynamic.Resource(cronTabs).Namespace("unit-test-ns").Get(context.TODO(), "unit-test-crontab", metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("Error while creating unstructured resource")
+	}
+
+	err = options.CreateResource("unit-test-string")
+	if err == nil {
+		t.Errorf("Error while creating unsupported resource")
+	}
+
+}
+
+func TestUpdateResource(t *testing.T) {
+
+	live, err := ToUnstructured(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-service", Namespace: "unit-test-ns", ResourceVersion: "12345"},
+		Spec:       v1.ServiceSpec{ClusterIP: "10.0.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	options := newUnstructuredOptions(live)
+
+	input := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-service", Namespace: "unit-test-ns", Labels: map[string]string{"app": "unit-test"}}}
+	err = options.UpdateResource(input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	output, err := options.GetUnstructuredObject(live)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if output.GetLabels()["app"] != "unit-test" {
+		t.Errorf("Error while updating resource")
+	}
+
+	if ip, _, _ := unstructured.NestedString(output.Object, "spec", "clusterIP"); ip != "10.0.0.1" {
+		t.Errorf("Immutable cluster IP is not carried over while updating service, got %v", ip)
+	}
+
+}
+
+func TestUpdateImmutableFields(t *testing.T) {
+
+	class, rewritten := "gp2", "standard"
+	livePVC, err := ToUnstructured(&v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-pvc", Namespace: "unit-test-ns", ResourceVersion: "12345"},
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "unit-test-pv", StorageClassName: &class},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	liveJob, err := ToUnstructured(&batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "unit-test-job", Namespace: "unit-test-ns", ResourceVersion: "12345"},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "unit-test-uid"}},
+			Template: v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"controller-uid": "unit-test-uid", "job-name": "unit-test-job"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	options := newUnstructuredOptions(livePVC, liveJob)
+	cs := options.clientset.(*testclient.Clientset)
+	cs.Resources[0].APIResources = append(cs.Resources[0].APIResources,
+		metav1.APIResource{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true, Verbs: metav1.Verbs{"get", "update"}})
+	cs.Resources = append(cs.Resources, &metav1.APIResourceList{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{
+		{Name: "jobs", Kind: "Job", Namespaced: true, Verbs: metav1.Verbs{"get", "update"}},
+	}})
+
+	// Sanitized copies with a rewritten storage class
+	inputs := []runtime.Object{
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "unit-test-pvc", Namespace: "unit-test-ns", Labels: map[string]string{"app": "unit-test"}},
+			Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &rewritten},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "unit-test-job", Namespace: "unit-test-ns", Labels: map[string]string{"app": "unit-test"}},
+			Spec:       batchv1.JobSpec{Template: v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "unit-test"}}}},
+		},
+	}
+	for _, input := range inputs {
+		if err := options.UpdateResource(input); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	pvc, err := options.GetUnstructuredObject(livePVC)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if pvc.GetLabels()["app"] != "unit-test" {
+		t.Errorf("Error while updating persistent volume claim")
+	}
+	volume, _, _ := unstructured.NestedString(pvc.Object, "spec", "volumeName")
+	storageClass, _, _ := unstructured.NestedString(pvc.Object, "spec", "storageClassName")
+	if volume != "unit-test-pv" || storageClass != class {
+		t.Errorf("Immutable volume and storage class are not carried over while updating persistent volume claim, got %v and %v", volume, storageClass)
+	}
+
+	job, err := options.GetUnstructuredObject(liveJob)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if job.GetLabels()["app"] != "unit-test" {
+		t.Errorf("Error while updating job")
+	}
+	selector, _, _ := unstructured.NestedStringMap(job.Object, "spec", "selector", "matchLabels")
+	labels, _, _ := unstructured.NestedStringMap(job.Object, "spec", "template", "metadata", "labels")
+	if selector["controller-uid"] != "unit-test-uid" || labels["controller-uid"] != "unit-test-uid" || labels["app"] != "" {
+		t.Errorf("Immutable selector and template labels are not carried over while updating job, got %v and %v", selector, labels)
+	}
+
+}
+
+func TestExistsResource(t *testing.T) {
+
+	options := newUnstructuredOptions(newCronTab("unit-test-crontab"))
+
+	exists, err := options.ExistsResource(newCronTab("unit-test-crontab"))
+	if err != nil || !exists {
+		t.Errorf("Error while checking existing resource")
+	}
+
+	exists, err = options.ExistsResource(newCronTab("unit-test-crontab-1"))
+	if err != nil || exists {
+		t.Errorf("Error while checking non existence resource")
+	}
+
+}
+
+func TestKindOf(t *testing.T) {
+
+	if kind := KindOf(&v1.ConfigMap{}); kind != "ConfigMap" {
+		t.Errorf("Unexpected kind %v for typed resource", kind)
+	}
+
+	if kind := KindOf(newCronTab("unit-test-crontab")); kind != "CronTab" {
+		t.Errorf("Unexpected kind %v for unstructured resource", kind)
+	}
+
+}
@@ -0,0 +1,106 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Reasons of the default skip rules
+const (
+	SkipOwned        = "owned by a controller"
+	SkipToken        = "service account token"
+	SkipImplicit     = "created by the cluster"
+	SkipMaintained   = "maintained by a controller"
+	SkipUserProvided = "skipped on request"
+)
+
+// maintainedAnnotations are set on the objects maintained by a controller of the cluster
+var maintainedAnnotations = []string{
+	"endpoints.kubernetes.io/last-change-trigger-time",
+	"control-plane.alpha.kubernetes.io/leader",
+}
+
+// Skipper leaves out the objects generated by the source cluster and its controllers,
+// which are generated again in the destination, along with the ones skipped on request
+type Skipper struct {
+	defaults bool
+	rules    []Reference
+}
+
+// NewSkipper returns a skipper applying the default rules when asked to and the
+// given rules, whose names are glob patterns
+func NewSkipper(defaults bool, rules []Reference) (*Skipper, error) {
+	for _, rule := range rules {
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %v", rule.Name)
+		}
+	}
+	return &Skipper{defaults: defaults, rules: rules}, nil
+}
+
+// Skip returns why the object is left out of the copy, or an empty string when it's copied
+func (s *Skipper) Skip(x interface{}) string {
+	obj, ok := x.(metav1.Object)
+	if s == nil || !ok {
+		return ""
+	}
+
+	ref := ReferenceOf(x)
+	for _, rule := range s.rules {
+		if matched, _ := path.Match(rule.Name, ref.Name); matched && rule.GroupKind() == ref.GroupKind() {
+			return SkipUserProvided
+		}
+	}
+
+	if !s.defaults {
+		return ""
+	}
+
+	switch {
+	case metav1.GetControllerOf(obj) != nil:
+		return SkipOwned
+	case implicitObjects[ref]:
+		return SkipImplicit
+	case secretTypeOf(x) == corev1.SecretTypeServiceAccountToken:
+		return SkipToken
+	}
+
+	for _, annotation := range maintainedAnnotations {
+		if _, ok := obj.GetAnnotations()[annotation]; ok {
+			return SkipMaintained
+		}
+	}
+	return ""
+}
+
+// secretTypeOf returns the type of a typed or unstructured secret
+func secretTypeOf(x interface{}) corev1.SecretType {
+	switch v := x.(type) {
+	case *corev1.Secret:
+		return v.Type
+	case *unstructured.Unstructured:
+		if v.GetKind() == "Secret" {
+			t, _, _ := unstructured.NestedString(v.Object, "type")
+			return corev1.SecretType(t)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSkipper(t *testing.T) {
+
+	controller := true
+	owned := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:            "unit-test-job-12345",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "CronJob", Name: "unit-test-job", Controller: &controller}},
+	}}
+	token := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "default-token-abcde"}, Type: v1.SecretTypeServiceAccountToken}
+	unstructuredToken := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "builder-token-abcde"},
+		"type":       string(v1.SecretTypeServiceAccountToken),
+	}}
+	rootCA := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt"}}
+	endpoints := &v1.Endpoints{ObjectMeta: metav1.ObjectMeta{
+		Name:        "unit-test-service",
+		Annotations: map[string]string{"endpoints.kubernetes.io/last-change-trigger-time": "2020-10-01T10:00:00Z"},
+	}}
+	release := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sh.helm.release.v1.checkout.v1"}, Type: "helm.sh/release.v1"}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-secret"}, Type: v1.SecretTypeOpaque}
+
+	skipper, err := NewSkipper(true, []Reference{{Kind: "Secret", Name: "sh.helm.release.*"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		object   interface{}
+		expected string
+	}{
+		{owned, SkipOwned},
+		{token, SkipToken},
+		{unstructuredToken, SkipToken},
+		{rootCA, SkipImplicit},
+		{endpoints, SkipMaintained},
+		{release, SkipUserProvided},
+		{secret, ""},
+	}
+
+	for _, test := range tests {
+		if reason := skipper.Skip(test.object); reason != test.expected {
+			t.Errorf("Error while skipping %v, got %q instead of %q", ReferenceOf(test.object), reason, test.expected)
+		}
+	}
+
+	skipper, err = NewSkipper(false, []Reference{{Kind: "Secret", Name: "sh.helm.release.*"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if skipper.Skip(owned) != "" || skipper.Skip(token) != "" || skipper.Skip(release) != SkipUserProvided {
+		t.Errorf("Error while skipping without the default rules")
+	}
+
+	if _, err := NewSkipper(true, []Reference{{Kind: "Secret", Name: "[release"}}); err == nil {
+		t.Errorf("Error while validating skip rule patterns")
+	}
+
+}
@@ -0,0 +1,190 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// APIResource is a namespaced API resource served by the cluster
+type APIResource struct {
+	schema.GroupVersionResource
+	Kind         string
+	SingularName string
+	ShortNames   []string
+}
+
+// GetAPIResources returns all the namespaced resources in their preferred version
+// which can be listed and created. Resources of the groups which failed discovery
+// are left out and reported with a discovery.ErrGroupDiscoveryFailed error along
+// with the rest of the resources
+func (kOpts *Options) GetAPIResources() (result []APIResource, err error) {
+	lists, err := discovery.ServerPreferredNamespacedResources(kOpts.clientset.Discovery())
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+
+	lists = discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list", "create"}}, lists)
+	for _, list := range lists {
+		gv, parseErr := schema.ParseGroupVersion(list.GroupVersion)
+		if parseErr != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+			result = append(result, APIResource{
+				GroupVersionResource: gv.WithResource(r.Name),
+				Kind:                 r.Kind,
+				SingularName:         r.SingularName,
+				ShortNames:           r.ShortNames,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Group != result[j].Group {
+			return result[i].Group < result[j].Group
+		}
+		return result[i].Resource < result[j].Resource
+	})
+	return
+}
+
+// GetUnstructured returns all the objects of the given resource in the given namespace
+func (kOpts *Options) GetUnstructured(resource APIResource) (result *unstructured.UnstructuredList, err error) {
+	result, err = kOpts.dynamic.
+		Resource(resource.GroupVersionResource).
+		Namespace(kOpts.namespace).
+		List(context.TODO(), kOpts.listOptions())
+	return
+}
+
+// DeleteUnstructured method deletes the given object
+func (kOpts *Options) DeleteUnstructured(obj *unstructured.Unstructured) (err error) {
+	client, err := kOpts.resourceClient(obj)
+	if err != nil {
+		return
+	}
+	err = client.Delete(context.TODO(), obj.GetName(), kOpts.deleteOptions())
+	return
+}
+
+// CreateUnstructured method creates the given object
+func (kOpts *Options) CreateUnstructured(obj *unstructured.Unstructured) (result *unstructured.Unstructured, err error) {
+	client, err := kOpts.resourceClient(obj)
+	if err != nil {
+		return
+	}
+	result, err = client.Create(context.TODO(), obj, kOpts.createOptions())
+	return
+}
+
+// GetUnstructuredObject returns the object in the cluster with the kind and name of the given one
+func (kOpts *Options) GetUnstructuredObject(obj *unstructured.Unstructured) (result *unstructured.Unstructured, err error) {
+	client, err := kOpts.resourceClient(obj)
+	if err != nil {
+		return
+	}
+	result, err = client.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	return
+}
+
+// UpdateUnstructured method updates the given object
+func (kOpts *Options) UpdateUnstructured(obj *unstructured.Unstructured) (result *unstructured.Unstructured, err error) {
+	client, err := kOpts.resourceClient(obj)
+	if err != nil {
+		return
+	}
+	result, err = client.Update(context.TODO(), obj, kOpts.updateOptions())
+	return
+}
+
+// ApplyUnstructured method applies the given object with server-side apply
+// using the kopy field manager
+func (kOpts *Options) ApplyUnstructured(obj *unstructured.Unstructured) (result *unstructured.Unstructured, err error) {
+	client, err := kOpts.resourceClient(obj)
+	if err != nil {
+		return
+	}
+
+	// Fields owned by the server in the source cluster can't be applied
+	obj = obj.DeepCopy()
+	obj.SetUID("")
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return
+	}
+	result, err = client.Patch(context.TODO(), obj.GetName(), types.ApplyPatchType, data, kOpts.patchOptions())
+	return
+}
+
+// ToUnstructured converts a typed resource into an unstructured object
+func ToUnstructured(x interface{}) (*unstructured.Unstructured, error) {
+	if u, ok := x.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+
+	obj, ok := x.(runtime.Object)
+	if !ok {
+		return nil, fmt.Errorf("unsupported resource type %T", x)
+	}
+
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvks[0])
+	return u, nil
+}
+
+// resourceClient maps the kind of an object to the resource served by the
+// cluster and returns the dynamic client for it
+func (kOpts *Options) resourceClient(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := kOpts.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return kOpts.dynamic.Resource(mapping.Resource), nil
+	}
+	return kOpts.dynamic.Resource(mapping.Resource).Namespace(kOpts.namespace), nil
+}
@@ -0,0 +1,223 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koperator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var cronTabs = schema.GroupVersionResource{Group: "stable.example.com", Version: "v1", Resource: "crontabs"}
+
+func newUnstructuredOptions(objects ...runtime.Object) Options {
+	cs := testclient.NewSimpleClientset()
+	cs.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "services", Kind: "Service", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "create", "update"}},
+			},
+		},
+		{
+			GroupVersion: "stable.example.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "crontabs", Kind: "CronTab", Namespaced: true, Verbs: metav1.Verbs{"list", "create", "delete"}},
+				{Name: "crontabs/status", Kind: "CronTab", Namespaced: true, Verbs: metav1.Verbs{"get", "update"}},
+				{Name: "cronreports", Kind: "CronReport", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+				{Name: "cronclusters", Kind: "CronCluster", Namespaced: false, Verbs: metav1.Verbs{"list", "create"}},
+			},
+		},
+	}
+
+	return Options{
+		clientset: cs,
+		dynamic:   dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...),
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(cs.Discovery())),
+		namespace: "unit-test-ns",
+	}
+}
+
+func newCronTab(name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("stable.example.com/v1")
+	u.SetKind("CronTab")
+	u.SetName(name)
+	u.SetNamespace("unit-test-ns")
+	u.SetResourceVersion("12345")
+	return u
+}
+
+func TestGetAPIResources(t *testing.T) {
+
+	options := newUnstructuredOptions()
+
+	output, err := options.GetAPIResources()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(output) != 2 || output[1].GroupVersionResource != cronTabs || output[1].Kind != "CronTab" {
+		t.Errorf("Error while discovering api resources, got %v", output)
+	}
+
+}
+
+func TestGetUnstructured(t *testing.T) {
+
+	options := newUnstructuredOptions(newCronTab("unit-test-crontab"))
+
+	output, err := options.GetUnstructured(APIResource{GroupVersionResource: cronTabs, Kind: "CronTab"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(output.Items) != 1 || output.Items[0].GetName() != "unit-test-crontab" {
+		t.Errorf("Error while getting unstructured objects")
+	}
+
+}
+
+func TestDeleteUnstructured(t *testing.T) {
+
+	options := newUnstructuredOptions(newCronTab("unit-test-crontab"))
+
+	err := options.DeleteUnstructured(newCronTab("unit-test-crontab"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = options.DeleteUnstructured(newCronTab("unit-test-crontab-1"))
+	if err == nil {
+		t.Errorf("Error while deleting non existence unstructured object")
+	}
+
+}
+
+func TestCreateUnstructured(t *testing.T) {
+
+	options := newUnstructuredOptions()
+
+	_, err := options.CreateUnstructured(newCronTab("unit-test-crontab"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	output, err := options.dynamic.Resource(cronTabs).Namespace("unit-test-ns").Get(context.TODO(), "unit-test-crontab", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if output.GetName() != "unit-test-crontab" {
+		t.Errorf("Error while retrieving created unstructured object")
+	}
+
+	unknown := newCronTab("unit-test-unknown")
+	unknown.SetKind("Unknown")
+	_, err = options.CreateUnstructured(unknown)
+	if err == nil {
+		t.Errorf("Error while creating object of a kind not served")
+	}
+
+}
+
+func TestUpdateUnstructured(t *testing.T) {
+
+	options := newUnstructuredOptions(newCronTab("unit-test-crontab"))
+
+	input := newCronTab("unit-test-crontab")
+	input.SetLabels(map[string]string{"app": "unit-test"})
+	_, err := options.UpdateUnstructured(input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	output, err := options.GetUnstructuredObject(input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if output.GetLabels()["app"] != "unit-test" {
+		t.Errorf("Error while updating unstructured object")
+	}
+
+	_, err = options.UpdateUnstructured(newCronTab("unit-test-crontab-1"))
+	if err == nil {
+		t.Errorf("Error while updating non existence unstructured object")
+	}
+
+}
+
+func TestToUnstructured(t *testing.T) {
+
+	output, err := ToUnstructured(&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unit-test-service"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if output.GetAPIVersion() != "v1" || output.GetKind() != "Service" || output.GetName() != "unit-test-service" {
+		t.Errorf("Error while converting typed resource, got %v", output.Object)
+	}
+
+	_, err = ToUnstructured("unit-test-string")
+	if err == nil {
+		t.Errorf("Error while converting unsupported resource")
+	}
+
+}
+
+func TestApplyUnstructured(t *testing.T) {
+
+	options := newUnstructuredOptions()
+	fake := options.dynamic.(*dynamicfake.FakeDynamicClient)
+
+	var patch clienttesting.PatchAction
+	fake.PrependReactor("patch", "crontabs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch = action.(clienttesting.PatchAction)
+		return true, newCronTab(patch.GetName()), nil
+	})
+
+	input := newCronTab("unit-test-crontab")
+	input.SetUID("unit-test-uid")
+	_, err := options.ApplyUnstructured(input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if patch == nil || patch.GetPatchType() != types.ApplyPatchType || patch.GetName() != "unit-test-crontab" {
+		t.Fatalf("Error while applying unstructured object")
+	}
+
+	if strings.Contains(string(patch.GetPatch()), "unit-test-uid") || strings.Contains(string(patch.GetPatch()), "resourceVersion") {
+		t.Errorf("Server owned fields are applied, got %s", patch.GetPatch())
+	}
+
+	if input.GetUID() != "unit-test-uid" {
+		t.Errorf("Applying unstructured object modifies the input")
+	}
+
+}